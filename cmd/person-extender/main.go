@@ -1,20 +1,48 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"person-extender/internal/config"
+	cacheList "person-extender/internal/http-server/handlers/cache/list"
+	cachePurge "person-extender/internal/http-server/handlers/cache/purge"
+	"person-extender/internal/http-server/handlers/health"
+	jobGet "person-extender/internal/http-server/handlers/job/get"
 	del "person-extender/internal/http-server/handlers/person/delete"
+	"person-extender/internal/http-server/handlers/person/duplicates"
+	"person-extender/internal/http-server/handlers/person/enrich"
+	"person-extender/internal/http-server/handlers/person/export"
+	"person-extender/internal/http-server/handlers/person/get"
 	"person-extender/internal/http-server/handlers/person/getall"
+	"person-extender/internal/http-server/handlers/person/history"
+	"person-extender/internal/http-server/handlers/person/importer"
+	"person-extender/internal/http-server/handlers/person/merge"
+	personPurge "person-extender/internal/http-server/handlers/person/purge"
+	"person-extender/internal/http-server/handlers/person/restore"
 	"person-extender/internal/http-server/handlers/person/save"
+	"person-extender/internal/http-server/handlers/person/search"
+	"person-extender/internal/http-server/handlers/person/stats"
 	"person-extender/internal/http-server/handlers/person/update"
+	mwAudit "person-extender/internal/http-server/middleware/audit"
+	mwIdempotency "person-extender/internal/http-server/middleware/idempotency"
 	mwLogger "person-extender/internal/http-server/middleware/logger"
+	"person-extender/internal/lib/api"
+	"person-extender/internal/lib/breaker"
 	"person-extender/internal/lib/logger/sl"
 	"person-extender/internal/lib/logger/slogpretty"
+	"person-extender/internal/lib/ratelimit"
 	"person-extender/internal/storage/postgres"
+	"person-extender/internal/worker/jobs"
+	"person-extender/internal/worker/reenrich"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const (
@@ -23,6 +51,11 @@ const (
 	envProd  = "prod"
 )
 
+const (
+	reenrichInline = "inline"
+	reenrichAsync  = "async"
+)
+
 func main() {
 	cfg := config.MustLoad()
 
@@ -38,6 +71,33 @@ func main() {
 	}
 	log.Info("storage successfully initialized")
 
+	breakers := breaker.NewGroup()
+
+	providers, workerProviders, err := setupProviders(log, cfg.Enrichment, cfg.Worker, storage, breakers)
+	if err != nil {
+		log.Error("failed to init enrichment providers", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var enricherOpts []api.EnricherOption
+	if cfg.Enrichment.AllowPartial {
+		enricherOpts = append(enricherOpts, api.WithPartialResults())
+	}
+
+	enricher := api.NewEnricher(providers, enricherOpts...)
+
+	var pool *jobs.Pool
+	if cfg.Jobs.Enabled {
+		pool = jobs.New(log, storage, api.NewEnricher(providers, api.WithPartialResults()), jobs.Config{
+			Workers:      cfg.Jobs.Workers,
+			QueueSize:    cfg.Jobs.QueueSize,
+			RecoverAfter: cfg.Jobs.RecoverAfter,
+			Lease:        cfg.Jobs.Lease,
+		})
+	}
+
+	refresher := setupRefresher(log, cfg.Enrichment, storage, providers, pool, cfg.Jobs.Lease)
+
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
@@ -45,11 +105,40 @@ func main() {
 	router.Use(mwLogger.New(log))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
+	router.Use(mwAudit.New())
 
-	router.Post("/persons", save.New(log, storage))
-	router.Put("/persons", update.New(log, storage))
+	router.With(mwIdempotency.New(log, storage, cfg.Idempotency.TTL, cfg.Idempotency.Lease)).Post("/persons", save.New(log, enricher, storage, jobEnqueuer(pool), cfg.Persons.DuplicatePolicy))
+	router.Post("/persons/import", importer.New(log, enricher, storage, importer.Config{
+		MaxRows:         cfg.Import.MaxRows,
+		Concurrency:     cfg.Import.Concurrency,
+		BatchSize:       cfg.Import.BatchSize,
+		DuplicatePolicy: cfg.Persons.DuplicatePolicy,
+	}))
+	router.Put("/persons/{id}", update.New(log, storage, refresher))
+	router.Patch("/persons/{id}", update.NewPatch(log, storage, refresher))
+	router.Post("/persons/{id}/enrich", enrich.New(log, refresher))
 	router.Delete("/persons/{id}", del.New(log, storage))
+	router.Post("/persons/{id}/restore", restore.New(log, storage))
+	router.Get("/persons/{id}/history", history.New(log, storage))
+	router.Post("/persons/{id}/revert", history.NewRevert(log, storage))
 	router.Get("/persons", getall.New(log, storage))
+	router.Get("/persons/export", export.New(log, storage))
+	router.Get("/persons/search", search.New(log, storage))
+	router.Get("/persons/duplicates", duplicates.New(log, storage))
+	router.Get("/persons/stats", stats.New(log, storage))
+	router.Post("/persons/merge", merge.New(log, storage))
+	router.Get("/persons/{id}", get.New(log, storage))
+
+	router.Get("/jobs/{id}", jobGet.New(log, storage))
+
+	router.Get("/health/providers", health.New(log, breakers))
+
+	router.Route("/admin", func(r chi.Router) {
+		r.Get("/enrichment-cache", cacheList.New(log, storage, cfg.Enrichment.CacheTTL))
+		r.Delete("/enrichment-cache", cachePurge.New(log, storage, cfg.Enrichment.CacheTTL))
+		r.Delete("/enrichment-cache/{name}", cachePurge.New(log, storage, cfg.Enrichment.CacheTTL))
+		r.Delete("/persons/deleted", personPurge.New(log, storage, cfg.Persons.PurgeAfter))
+	})
 
 	log.Info("server started", slog.String("address", cfg.Address))
 
@@ -61,13 +150,167 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server", sl.Err(err))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+
+	if cfg.Worker.Enabled {
+		worker := reenrich.New(log, storage, api.NewEnricher(workerProviders, api.WithPartialResults()), reenrich.Config{
+			Interval:    cfg.Worker.Interval,
+			StaleAfter:  cfg.Worker.StaleAfter,
+			RetryAfter:  cfg.Worker.RetryAfter,
+			Lease:       cfg.Worker.Lease,
+			BatchSize:   cfg.Worker.BatchSize,
+			Concurrency: cfg.Worker.Concurrency,
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.Run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		purgeIdempotencyKeys(ctx, log, storage, cfg.Idempotency)
+	}()
+
+	if pool != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.Run(ctx)
+		}()
 	}
 
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to start server", sl.Err(err))
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	log.Info("stopping server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("failed to stop server", sl.Err(err))
+	}
+
+	wg.Wait()
+
 	log.Info("server stopped")
 }
 
+// jobEnqueuer keeps a disabled pool from turning into a non-nil interface
+// holding a nil pointer.
+func jobEnqueuer(pool *jobs.Pool) save.JobEnqueuer {
+	if pool == nil {
+		return nil
+	}
+
+	return pool
+}
+
+// purgeIdempotencyKeys removes expired idempotency keys every
+// cfg.PurgeInterval until ctx is done.
+func purgeIdempotencyKeys(ctx context.Context, log *slog.Logger, storage *postgres.Storage, cfg config.Idempotency) {
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := storage.PurgeIdempotencyKeys(ctx, cfg.TTL)
+			if err != nil {
+				log.Error("failed to purge idempotency keys", sl.Err(err))
+
+				continue
+			}
+			log.Debug("idempotency keys purged", slog.Int64("count", n))
+		}
+	}
+}
+
+// setupRefresher re-enriches on demand through the job pool in async mode and
+// falls back to inline enrichment when the pool is disabled.
+func setupRefresher(log *slog.Logger, cfg config.Enrichment, storage *postgres.Storage, providers []api.Provider, pool *jobs.Pool, lease time.Duration) *reenrich.Refresher {
+	enricher := api.NewEnricher(providers, api.WithPartialResults())
+
+	switch {
+	case cfg.ReenrichMode == reenrichAsync && pool != nil:
+		return reenrich.NewRefresher(log, storage, enricher, pool, lease)
+	case cfg.ReenrichMode == reenrichAsync:
+		log.Warn("jobs are disabled, re-enriching inline")
+	case cfg.ReenrichMode != reenrichInline:
+		log.Warn("unknown re-enrich mode, re-enriching inline", slog.String("mode", cfg.ReenrichMode))
+	}
+
+	return reenrich.NewRefresher(log, storage, enricher, nil, lease)
+}
+
+// setupProviders builds the configured providers and wraps each one, from the
+// inside out, in its circuit breaker, retry policy and the enrichment cache.
+// The second list shares breakers and cache but is additionally rate limited
+// for the background worker.
+func setupProviders(log *slog.Logger, cfg config.Enrichment, workerCfg config.Worker, cache api.Cache, breakers *breaker.Group) ([]api.Provider, []api.Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	providerCfgs := make([]api.ProviderConfig, 0, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providerCfgs = append(providerCfgs, api.ProviderConfig{
+			Name:    p.Name,
+			Kind:    p.Kind,
+			URL:     p.URL,
+			Options: p.Options,
+		})
+	}
+
+	providers, err := api.DefaultRegistry().Build(client, providerCfgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workerProviders := make([]api.Provider, len(providers))
+
+	for i, p := range providers {
+		providerCfg := cfg.Providers[i]
+
+		b := breaker.New(p.Name(), providerCfg.Breaker.FailureThreshold, providerCfg.Breaker.OpenTimeout)
+		breakers.Add(b)
+
+		policy := api.RetryPolicy{
+			MaxAttempts:   providerCfg.Retry.MaxAttempts,
+			BaseDelay:     providerCfg.Retry.BaseDelay,
+			MaxDelay:      providerCfg.Retry.MaxDelay,
+			JitterPercent: providerCfg.Retry.JitterPercent,
+		}
+
+		p = api.WithBreaker(p, b)
+		providers[i] = api.Cached(log, api.WithRetry(p, policy), cache, cfg.CacheTTL)
+
+		rate, ok := workerCfg.RateLimits[p.Name()]
+		if !ok {
+			rate = workerCfg.DefaultRateLimit
+		}
+		// Every retry waits for its own token, so retries cannot burst past
+		// the provider's limit.
+		limited := api.WithRateLimit(p, ratelimit.New(rate, 1))
+		workerProviders[i] = api.Cached(log, api.WithRetry(limited, policy), cache, cfg.CacheTTL)
+	}
+
+	return providers, workerProviders, nil
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
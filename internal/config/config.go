@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
@@ -9,9 +10,15 @@ import (
 )
 
 type Config struct {
-	Env        string `yaml:"env" env-default:"local"`
-	HTTPServer `yaml:"http_server"`
-	Postgres   `yaml:"postgres"`
+	Env         string `yaml:"env" env-default:"local"`
+	HTTPServer  `yaml:"http_server"`
+	Postgres    `yaml:"postgres"`
+	Enrichment  `yaml:"enrichment"`
+	Worker      `yaml:"worker"`
+	Jobs        `yaml:"jobs"`
+	Import      `yaml:"import"`
+	Persons     `yaml:"persons"`
+	Idempotency `yaml:"idempotency"`
 }
 
 type HTTPServer struct {
@@ -28,6 +35,77 @@ type Postgres struct {
 	DBName   string `yaml:"db_name" env-default:"postgres"`
 }
 
+type Enrichment struct {
+	Timeout        time.Duration `yaml:"timeout" env-default:"5s"`
+	CacheTTL       time.Duration `yaml:"cache_ttl" env-default:"168h"`
+	AllowPartial   bool          `yaml:"allow_partial" env-default:"false"`
+	ReenrichMode   string        `yaml:"reenrich_mode" env-default:"inline"`
+	AgifyURL       string        `yaml:"agify_url" env:"API_AGIFY_URL"`
+	GenderizeURL   string        `yaml:"genderize_url" env:"API_GENDERIZE_URL"`
+	NationalizeURL string        `yaml:"nationalize_url" env:"API_NATIONALIZE_URL"`
+	Providers      []Provider    `yaml:"providers"`
+}
+
+type Worker struct {
+	Enabled          bool               `yaml:"enabled" env-default:"true"`
+	Interval         time.Duration      `yaml:"interval" env-default:"1m"`
+	StaleAfter       time.Duration      `yaml:"stale_after" env-default:"720h"`
+	RetryAfter       time.Duration      `yaml:"retry_after" env-default:"10m"`
+	Lease            time.Duration      `yaml:"lease" env-default:"5m"`
+	BatchSize        int                `yaml:"batch_size" env-default:"50"`
+	Concurrency      int                `yaml:"concurrency" env-default:"4"`
+	DefaultRateLimit float64            `yaml:"default_rate_limit" env-default:"1"`
+	RateLimits       map[string]float64 `yaml:"rate_limits"`
+}
+
+type Jobs struct {
+	Enabled      bool          `yaml:"enabled" env-default:"true"`
+	Workers      int           `yaml:"workers" env-default:"4"`
+	QueueSize    int           `yaml:"queue_size" env-default:"1000"`
+	RecoverAfter time.Duration `yaml:"recover_after" env-default:"5m"`
+	Lease        time.Duration `yaml:"lease" env-default:"5m"`
+}
+
+type Import struct {
+	MaxRows     int `yaml:"max_rows" env-default:"10000"`
+	Concurrency int `yaml:"concurrency" env-default:"8"`
+	BatchSize   int `yaml:"batch_size" env-default:"500"`
+}
+
+type Persons struct {
+	PurgeAfter      time.Duration `yaml:"purge_after" env-default:"720h"`
+	DuplicatePolicy string        `yaml:"duplicate_policy" env-default:"allow"`
+}
+
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env-default:"24h"`
+	// Lease bounds how long a request may hold its key before a retry can
+	// take it over; it should exceed the longest request.
+	Lease         time.Duration `yaml:"lease" env-default:"5m"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+type Provider struct {
+	Name    string            `yaml:"name"`
+	Kind    string            `yaml:"kind"`
+	URL     string            `yaml:"url"`
+	Options map[string]string `yaml:"options"`
+	Retry   Retry             `yaml:"retry"`
+	Breaker Breaker           `yaml:"breaker"`
+}
+
+type Retry struct {
+	MaxAttempts   uint64        `yaml:"max_attempts"`
+	BaseDelay     time.Duration `yaml:"base_delay"`
+	MaxDelay      time.Duration `yaml:"max_delay"`
+	JitterPercent uint64        `yaml:"jitter_percent"`
+}
+
+type Breaker struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+}
+
 func MustLoad() *Config {
 	err := godotenv.Load()
 	if err != nil {
@@ -49,5 +127,92 @@ func MustLoad() *Config {
 		log.Fatalf("failed to load config: %s", err)
 	}
 
+	cfg.Enrichment.setProviderDefaults()
+
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
+
 	return &cfg
 }
+
+// validate rejects the values the struct tags cannot.
+func (c *Config) validate() error {
+	if err := c.Persons.validate(); err != nil {
+		return err
+	}
+	if err := c.Jobs.validate(); err != nil {
+		return err
+	}
+
+	return c.Idempotency.validate()
+}
+
+func (j Jobs) validate() error {
+	if j.RecoverAfter <= 0 || j.Lease <= 0 {
+		return fmt.Errorf("jobs recover_after and lease must be positive")
+	}
+
+	return nil
+}
+
+func (i Idempotency) validate() error {
+	if i.TTL <= 0 || i.Lease <= 0 || i.PurgeInterval <= 0 {
+		return fmt.Errorf("idempotency ttl, lease and purge_interval must be positive")
+	}
+
+	return nil
+}
+
+func (p Persons) validate() error {
+	switch p.DuplicatePolicy {
+	case "allow", "reject", "return_existing":
+		return nil
+	}
+
+	return fmt.Errorf("unknown duplicate_policy %q", p.DuplicatePolicy)
+}
+
+// setProviderDefaults falls back to the public agify, genderize and
+// nationalize APIs when no providers are configured, fills in their URLs
+// from the environment and sets retry and breaker defaults.
+func (e *Enrichment) setProviderDefaults() {
+	if len(e.Providers) == 0 {
+		e.Providers = []Provider{
+			{Name: "agify", Kind: "agify"},
+			{Name: "genderize", Kind: "genderize"},
+			{Name: "nationalize", Kind: "nationalize"},
+		}
+	}
+
+	defaultURLs := map[string]string{
+		"agify":       e.AgifyURL,
+		"genderize":   e.GenderizeURL,
+		"nationalize": e.NationalizeURL,
+	}
+
+	for i := range e.Providers {
+		p := &e.Providers[i]
+
+		if p.URL == "" {
+			p.URL = defaultURLs[p.Kind]
+		}
+
+		if p.Retry.MaxAttempts == 0 {
+			p.Retry.MaxAttempts = 3
+		}
+		if p.Retry.BaseDelay == 0 {
+			p.Retry.BaseDelay = 200 * time.Millisecond
+		}
+		if p.Retry.MaxDelay == 0 {
+			p.Retry.MaxDelay = 2 * time.Second
+		}
+
+		if p.Breaker.FailureThreshold == 0 {
+			p.Breaker.FailureThreshold = 5
+		}
+		if p.Breaker.OpenTimeout == 0 {
+			p.Breaker.OpenTimeout = 30 * time.Second
+		}
+	}
+}
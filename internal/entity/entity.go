@@ -1,20 +1,216 @@
 package entity
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EnrichmentPending  = "pending"
+	EnrichmentComplete = "complete"
+	EnrichmentPartial  = "partial"
+	EnrichmentFailed   = "failed"
+)
+
+const (
+	ProviderOK     = "ok"
+	ProviderFailed = "failed"
+	// ProviderCancelled marks a lookup cut short because another provider
+	// failed first.
+	ProviderCancelled = "cancelled"
+)
+
+// EnrichmentState summarizes the Provider* statuses of providers as one of
+// the Enrichment* statuses.
+func EnrichmentState(providers map[string]string) string {
+	var ok, failed int
+	for _, status := range providers {
+		if status == ProviderOK {
+			ok++
+		} else {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return EnrichmentComplete
+	case ok == 0:
+		return EnrichmentFailed
+	default:
+		return EnrichmentPartial
+	}
+}
+
 type Person struct {
-	ID         int64  `json:"id"`
-	Name       string `json:"name"`
-	Surname    string `json:"surname"`
-	Patronymic string `json:"patronymic,omitempty"`
-	Age        int64  `json:"age"`
-	Gender     string `json:"gender"`
-	Country    string `json:"country"`
+	ID                int64              `json:"id"`
+	Name              string             `json:"name"`
+	Surname           string             `json:"surname"`
+	Patronymic        string             `json:"patronymic,omitempty"`
+	Age               *int64             `json:"age"`
+	AgeCount          *int64             `json:"age_count,omitempty"`
+	Gender            *string            `json:"gender"`
+	GenderProbability *float64           `json:"gender_probability,omitempty"`
+	GenderCount       *int64             `json:"gender_count,omitempty"`
+	Country           *string            `json:"country"`
+	Countries         []CountryCandidate `json:"countries,omitempty"`
+	EnrichmentStatus  string             `json:"enrichment_status,omitempty"`
+	Enrichment        map[string]string  `json:"enrichment,omitempty"`
+	EnrichedAt        *time.Time         `json:"enriched_at,omitempty"`
+	Version           int64              `json:"version"`
+	DeletedAt         *time.Time         `json:"deleted_at,omitempty"`
+	MergedInto        *int64             `json:"merged_into,omitempty"`
+}
+
+type CountryCandidate struct {
+	CountryID   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// PendingFields lists the enriched fields that are still unresolved.
+func (p *Person) PendingFields() []string {
+	var pending []string
+
+	if p.Age == nil {
+		pending = append(pending, "age")
+	}
+	if p.Gender == nil {
+		pending = append(pending, "gender")
+	}
+	if p.Country == nil {
+		pending = append(pending, "country")
+	}
+
+	return pending
 }
 
+// Filters selects and orders the persons of a listing, see package filters
+// for the query parameters they are parsed from. All Conditions must hold;
+// if there are Groups, additionally all conditions of at least one group
+// must hold. An empty Sort orders by id.
 type Filters struct {
-	Name       *string `json:"name,omitempty"`
-	Surname    *string `json:"surname,omitempty"`
-	Patronymic *string `json:"patronymic,omitempty"`
-	Age        *int64  `json:"age,omitempty"`
-	Gender     *string `json:"gender,omitempty"`
-	Country    *string `json:"country,omitempty"`
+	Conditions     []Condition
+	Groups         [][]Condition
+	Sort           []SortKey
+	IncludeDeleted bool
+}
+
+// Condition compares the person field Field with Values, Op being one of
+// the filter operators such as "eq" or "gte".
+type Condition struct {
+	Field  string
+	Op     string
+	Values []interface{}
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+type CacheEntry struct {
+	Name      string          `json:"name"`
+	Provider  string          `json:"provider"`
+	Payload   json.RawMessage `json:"payload"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	// JobSkipped marks a job whose person was being enriched by someone else.
+	JobSkipped = "skipped"
+)
+
+const JobKindEnrich = "enrich"
+
+type Job struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	PersonID  int64     `json:"person_id"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	HistoryCreate  = "create"
+	HistoryUpdate  = "update"
+	HistoryDelete  = "delete"
+	HistoryRestore = "restore"
+	HistoryEnrich  = "enrich"
+	HistoryRevert  = "revert"
+	HistoryMerge   = "merge"
+	HistoryPurge   = "purge"
+)
+
+// HistoryEntry is one change of a person. Old is nil for HistoryCreate and
+// equals New for HistoryPurge; Version is the version New was stored with.
+type HistoryEntry struct {
+	ID        int64     `json:"id"`
+	PersonID  int64     `json:"person_id"`
+	Action    string    `json:"action"`
+	Version   int64     `json:"version"`
+	Old       *Person   `json:"old"`
+	New       *Person   `json:"new"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PersonMatch is a search result, Score grows with relevance.
+type PersonMatch struct {
+	*Person
+	Score float64 `json:"score"`
+}
+
+// DuplicateCluster groups live persons whose names only differ in case,
+// spacing or transliteration.
+type DuplicateCluster struct {
+	Key     string    `json:"key"`
+	Persons []*Person `json:"persons"`
+}
+
+// AgeBucket counts persons aged From up to, but not including, To. The last
+// bucket has no upper bound.
+type AgeBucket struct {
+	From  int64  `json:"from"`
+	To    *int64 `json:"to"`
+	Count int64  `json:"count"`
+}
+
+// GenderStat aggregates persons by gender; a nil Gender stands for persons
+// whose gender is unknown.
+type GenderStat struct {
+	Gender     *string  `json:"gender"`
+	Count      int64    `json:"count"`
+	AverageAge *float64 `json:"average_age"`
+}
+
+type CountryStat struct {
+	Country    string   `json:"country"`
+	Count      int64    `json:"count"`
+	AverageAge *float64 `json:"average_age"`
+}
+
+// PersonStats aggregates the persons matching a filter. Ages leaves out the
+// UnknownAge persons without an age.
+type PersonStats struct {
+	Total      int64
+	Ages       []*AgeBucket
+	UnknownAge int64
+	Genders    []*GenderStat
+	Countries  []*CountryStat
+}
+
+// IdempotentResponse is the response stored for an Idempotency-Key, replayed
+// to retries of the same request.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	Header      map[string]string
+	Body        []byte
 }
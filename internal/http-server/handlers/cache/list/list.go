@@ -0,0 +1,110 @@
+package list
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"strconv"
+	"time"
+)
+
+const defaultLimit = 100
+
+type Entry struct {
+	*entity.CacheEntry
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+}
+
+type Response struct {
+	resp.Response
+	Entries []Entry `json:"entries"`
+}
+
+type CacheLister interface {
+	ListCachedEnrichments(ctx context.Context, name *string, limit, offset int64) ([]*entity.CacheEntry, error)
+}
+
+func New(log *slog.Logger, cacheLister CacheLister, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.cache.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var name *string
+		if n := r.URL.Query().Get("name"); n != "" {
+			normalized := api.NormalizeName(n)
+			name = &normalized
+		}
+
+		limit := int64(defaultLimit)
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.ParseInt(l, 10, 64)
+			if err != nil || limit < 0 {
+				log.Error("invalid limit value", slog.String("limit", l))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid limit value"))
+
+				return
+			}
+		}
+
+		var offset int64
+		if o := r.URL.Query().Get("offset"); o != "" {
+			var err error
+			offset, err = strconv.ParseInt(o, 10, 64)
+			if err != nil || offset < 0 {
+				log.Error("invalid offset value", slog.String("offset", o))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid offset value"))
+
+				return
+			}
+		}
+
+		entries, err := cacheLister.ListCachedEnrichments(r.Context(), name, limit, offset)
+		if err != nil {
+			log.Error("failed to list enrichment cache", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("enrichment cache successfully listed", slog.Int("count", len(entries)))
+
+		responseOK(w, r, entries, ttl)
+	}
+}
+
+func responseOK(w http.ResponseWriter, r *http.Request, entries []*entity.CacheEntry, ttl time.Duration) {
+	now := time.Now()
+
+	res := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		expiresAt := e.FetchedAt.Add(ttl)
+
+		res = append(res, Entry{
+			CacheEntry: e,
+			ExpiresAt:  expiresAt,
+			Expired:    !now.Before(expiresAt),
+		})
+	}
+
+	render.JSON(w, r, Response{
+		Response: resp.OK(),
+		Entries:  res,
+	})
+}
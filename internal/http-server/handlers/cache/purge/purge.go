@@ -0,0 +1,70 @@
+package purge
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/lib/api"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"strconv"
+	"time"
+)
+
+type Response struct {
+	resp.Response
+	Purged int64 `json:"purged"`
+}
+
+type CachePurger interface {
+	PurgeCachedEnrichments(ctx context.Context, name string, olderThan time.Duration) (int64, error)
+}
+
+// New purges the whole cache, a single name (the {name} URL param) or, with
+// ?expired=true, only entries older than ttl.
+func New(log *slog.Logger, cachePurger CachePurger, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.cache.purge.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		name := api.NormalizeName(chi.URLParam(r, "name"))
+
+		var olderThan time.Duration
+		if e := r.URL.Query().Get("expired"); e != "" {
+			expired, err := strconv.ParseBool(e)
+			if err != nil {
+				log.Error("failed to convert expired value", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("invalid expired value"))
+
+				return
+			}
+			if expired {
+				olderThan = ttl
+			}
+		}
+
+		purged, err := cachePurger.PurgeCachedEnrichments(r.Context(), name, olderThan)
+		if err != nil {
+			log.Error("failed to purge enrichment cache", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("enrichment cache successfully purged", slog.Int64("purged", purged))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Purged:   purged,
+		})
+	}
+}
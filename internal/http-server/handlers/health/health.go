@@ -0,0 +1,48 @@
+package health
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/breaker"
+)
+
+type Response struct {
+	resp.Response
+	Degraded  bool               `json:"degraded"`
+	Providers []breaker.Snapshot `json:"providers"`
+}
+
+type BreakerReporter interface {
+	Snapshots() []breaker.Snapshot
+}
+
+func New(log *slog.Logger, breakerReporter BreakerReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		snapshots := breakerReporter.Snapshots()
+
+		var degraded bool
+		for _, s := range snapshots {
+			if s.State != breaker.StateClosed {
+				degraded = true
+			}
+		}
+
+		log.Debug("provider health reported", slog.Bool("degraded", degraded))
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Degraded:  degraded,
+			Providers: snapshots,
+		})
+	}
+}
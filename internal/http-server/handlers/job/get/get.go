@@ -0,0 +1,93 @@
+package get
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
+)
+
+type Response struct {
+	resp.Response
+	Job    *entity.Job    `json:"job"`
+	Person *entity.Person `json:"person,omitempty"`
+}
+
+type JobGetter interface {
+	GetJob(ctx context.Context, ID int64) (*entity.Job, error)
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+func New(log *slog.Logger, jobGetter JobGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.job.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		jobID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
+		job, err := jobGetter.GetJob(r.Context(), jobID)
+		if errors.Is(err, storage.ErrJobNotFound) {
+			log.Info("job not found", slog.Int64("id", jobID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("job not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get job", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		var person *entity.Person
+		if job.Status == entity.JobSucceeded || job.Status == entity.JobFailed || job.Status == entity.JobSkipped {
+			person, err = jobGetter.GetPerson(r.Context(), job.PersonID)
+			if err != nil && !errors.Is(err, storage.ErrPersonNotFound) {
+				log.Error("failed to get person", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+		}
+
+		log.Info("job successfully got", slog.Int64("id", jobID))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Job:      job,
+			Person:   person,
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package delete
 
 import (
+	"context"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
@@ -8,13 +10,15 @@ import (
 	"net/http"
 	resp "person-extender/internal/lib/api/response"
 	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
 	"strconv"
 )
 
 type PersonDeleter interface {
-	DeletePerson(ID int64) error
+	DeletePerson(ctx context.Context, ID int64) error
 }
 
+// New soft deletes a person, see the restore handler and the admin purge.
 func New(log *slog.Logger, personDeleter PersonDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.person.delete.New"
@@ -42,7 +46,15 @@ func New(log *slog.Logger, personDeleter PersonDeleter) http.HandlerFunc {
 			return
 		}
 
-		err = personDeleter.DeletePerson(personID)
+		err = personDeleter.DeletePerson(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
 		if err != nil {
 			log.Error("failed to delete person", sl.Err(err))
 
@@ -51,6 +63,8 @@ func New(log *slog.Logger, personDeleter PersonDeleter) http.HandlerFunc {
 			return
 		}
 
-		log.Info("personnel deleted successfully")
+		log.Info("personnel deleted successfully", slog.Int64("id", personID))
+
+		render.JSON(w, r, resp.OK())
 	}
 }
@@ -0,0 +1,66 @@
+package duplicates
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/params"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type Response struct {
+	resp.Response
+	Clusters []*entity.DuplicateCluster `json:"clusters"`
+}
+
+type DuplicatesLister interface {
+	ListDuplicates(ctx context.Context, limit, offset int64) ([]*entity.DuplicateCluster, error)
+}
+
+// New lists groups of live persons sharing a normalized full name, largest
+// groups first, to be resolved with the merge handler.
+func New(log *slog.Logger, duplicatesLister DuplicatesLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.duplicates.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		limit, ok := params.Int(w, r, log, "limit", defaultLimit, 1, maxLimit)
+		if !ok {
+			return
+		}
+
+		offset, ok := params.Int(w, r, log, "offset", 0, 0, -1)
+		if !ok {
+			return
+		}
+
+		clusters, err := duplicatesLister.ListDuplicates(r.Context(), limit, offset)
+		if err != nil {
+			log.Error("failed to list duplicates", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("duplicates successfully listed", slog.Int("clusters", len(clusters)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Clusters: clusters,
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/etag"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
+)
+
+type Response struct {
+	resp.Response
+	Person *entity.Person `json:"person,omitempty"`
+	JobID  int64          `json:"job_id,omitempty"`
+}
+
+type PersonRefresher interface {
+	Refresh(ctx context.Context, ID int64) (*entity.Person, *entity.Job, error)
+}
+
+// New re-runs enrichment for a person. Inline refreshes answer 200 with the
+// enriched person, queued ones 202 with the job ID.
+func New(log *slog.Logger, personRefresher PersonRefresher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.enrich.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		personID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
+		person, job, err := personRefresher.Refresh(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrPersonClaimed) || errors.Is(err, storage.ErrVersionConflict) {
+			log.Info("person is being enriched or changed concurrently", slog.Int64("id", personID), sl.Err(err))
+
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("person is being changed concurrently, try again"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to re-enrich person", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		if job != nil {
+			log.Info("re-enrichment queued", slog.Int64("id", personID), slog.Int64("job_id", job.ID))
+
+			w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+
+			render.Status(r, http.StatusAccepted)
+			render.JSON(w, r, Response{
+				Response: resp.OK(),
+				JobID:    job.ID,
+			})
+
+			return
+		}
+
+		log.Info("person re-enriched", slog.Int64("id", personID), slog.String("enrichment_status", person.EnrichmentStatus))
+
+		w.Header().Set("ETag", etag.Format(person.Version))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Person:   person,
+		})
+	}
+}
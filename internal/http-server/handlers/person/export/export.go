@@ -0,0 +1,292 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/filters"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"strconv"
+	"time"
+)
+
+const flushEvery = 1000
+
+type PersonsExporter interface {
+	ExportPersons(ctx context.Context, filters *entity.Filters, fn func(*entity.Person) error) error
+}
+
+// encoder writes one export format. begin runs before the first person,
+// end after the last one.
+type encoder interface {
+	contentType() string
+	extension() string
+	begin(w io.Writer) error
+	encode(w io.Writer, p *entity.Person) error
+	end(w io.Writer) error
+}
+
+func New(log *slog.Logger, personsExporter PersonsExporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.export.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "csv"
+		}
+
+		enc, err := newEncoder(format)
+		if err != nil {
+			log.Error("unsupported export format", slog.String("format", format))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid format value"))
+
+			return
+		}
+
+		f, err := filters.FromQuery(r.URL.Query(), "format")
+		if err != nil {
+			log.Error("invalid filters", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		// Exports outlive the server-wide write timeout.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("failed to lift write deadline", sl.Err(err))
+		}
+
+		var (
+			started bool
+			count   int
+		)
+
+		err = personsExporter.ExportPersons(r.Context(), f, func(p *entity.Person) error {
+			if !started {
+				started = true
+
+				writeHeaders(w, enc)
+				if err := enc.begin(w); err != nil {
+					return err
+				}
+			}
+
+			if err := enc.encode(w, p); err != nil {
+				return err
+			}
+
+			count++
+			if count%flushEvery == 0 {
+				return rc.Flush()
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Error("failed to export persons", slog.Int("exported", count), sl.Err(err))
+
+			if !started {
+				render.JSON(w, r, resp.Error("internal error"))
+			}
+
+			// Otherwise the body is already partially sent and the client
+			// sees a truncated document.
+			return
+		}
+
+		if !started {
+			writeHeaders(w, enc)
+			if err := enc.begin(w); err != nil {
+				log.Error("failed to write export", sl.Err(err))
+
+				return
+			}
+		}
+
+		if err := enc.end(w); err != nil {
+			log.Error("failed to write export", sl.Err(err))
+
+			return
+		}
+
+		log.Info("persons successfully exported", slog.String("format", format), slog.Int("count", count))
+	}
+}
+
+func writeHeaders(w http.ResponseWriter, enc encoder) {
+	filename := fmt.Sprintf("persons-%s.%s", time.Now().UTC().Format("20060102T150405Z"), enc.extension())
+
+	w.Header().Set("Content-Type", enc.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+}
+
+func newEncoder(format string) (encoder, error) {
+	switch format {
+	case "csv":
+		return &csvEncoder{}, nil
+	case "ndjson":
+		return &ndjsonEncoder{}, nil
+	case "json":
+		return &jsonEncoder{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+var csvHeader = []string{
+	"id", "name", "surname", "patronymic", "age", "age_count", "gender", "gender_probability", "gender_count",
+	"country", "enrichment_status", "enriched_at",
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) contentType() string { return "text/csv; charset=utf-8" }
+func (e *csvEncoder) extension() string   { return "csv" }
+
+func (e *csvEncoder) begin(w io.Writer) error {
+	e.w = csv.NewWriter(w)
+
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) encode(_ io.Writer, p *entity.Person) error {
+	var enrichedAt string
+	if p.EnrichedAt != nil {
+		enrichedAt = p.EnrichedAt.UTC().Format(time.RFC3339)
+	}
+
+	err := e.w.Write([]string{
+		strconv.FormatInt(p.ID, 10),
+		p.Name,
+		p.Surname,
+		p.Patronymic,
+		formatInt(p.Age),
+		formatInt(p.AgeCount),
+		formatString(p.Gender),
+		formatFloat(p.GenderProbability),
+		formatInt(p.GenderCount),
+		formatString(p.Country),
+		p.EnrichmentStatus,
+		enrichedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Push the row out of the csv buffer so Flush on the response sends it.
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+func (e *csvEncoder) end(_ io.Writer) error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) contentType() string { return "application/x-ndjson" }
+func (e *ndjsonEncoder) extension() string   { return "ndjson" }
+
+func (e *ndjsonEncoder) begin(w io.Writer) error {
+	e.enc = json.NewEncoder(w)
+
+	return nil
+}
+
+func (e *ndjsonEncoder) encode(_ io.Writer, p *entity.Person) error {
+	return e.enc.Encode(p)
+}
+
+func (e *ndjsonEncoder) end(_ io.Writer) error {
+	return nil
+}
+
+// jsonEncoder writes a single JSON array, one element at a time.
+type jsonEncoder struct {
+	first bool
+}
+
+func (e *jsonEncoder) contentType() string { return "application/json" }
+func (e *jsonEncoder) extension() string   { return "json" }
+
+func (e *jsonEncoder) begin(w io.Writer) error {
+	e.first = true
+
+	_, err := io.WriteString(w, "[")
+
+	return err
+}
+
+func (e *jsonEncoder) encode(w io.Writer, p *entity.Person) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if !e.first {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	e.first = false
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+func (e *jsonEncoder) end(w io.Writer) error {
+	_, err := io.WriteString(w, "]\n")
+
+	return err
+}
+
+func formatInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+
+	return *v
+}
@@ -0,0 +1,90 @@
+package get
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/etag"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
+)
+
+type Response struct {
+	resp.Response
+	Person *entity.Person `json:"person"`
+}
+
+type PersonGetter interface {
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+func New(log *slog.Logger, personGetter PersonGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		personID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
+		person, err := personGetter.GetPerson(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get person", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		tag := etag.Format(person.Version)
+		w.Header().Set("ETag", tag)
+
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etag.Match(inm, tag) {
+			log.Info("person not modified", slog.Int64("id", personID))
+
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		log.Info("person successfully got", slog.Int64("id", personID))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Person:   person,
+		})
+	}
+}
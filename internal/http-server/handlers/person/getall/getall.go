@@ -1,37 +1,52 @@
 package getall
 
 import (
-	"errors"
+	"context"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
-	"github.com/go-playground/validator/v10"
-	"io"
 	"log/slog"
 	"net/http"
 	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/filters"
+	"person-extender/internal/lib/api/params"
 	resp "person-extender/internal/lib/api/response"
 	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/lib/query"
 	"strconv"
 )
 
-type Request struct {
-	Name       *string `json:"name,omitempty"`
-	Surname    *string `json:"surname,omitempty"`
-	Patronymic *string `json:"patronymic,omitempty"`
-	Age        *int64  `json:"age,omitempty"`
-	Gender     *string `json:"gender,omitempty"`
-	Country    *string `json:"country,omitempty"`
-}
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
 
 type Response struct {
 	resp.Response
-	Persons []*entity.Person `json:"persons"`
+	Persons    []*entity.Person `json:"persons"`
+	Pagination Pagination       `json:"pagination"`
+}
+
+// Pagination describes the page. Offset is set in offset mode, Cursor in
+// cursor mode; Total only with ?with_total=true.
+type Pagination struct {
+	Total      *int64 `json:"total,omitempty"`
+	Limit      int64  `json:"limit"`
+	Offset     *int64 `json:"offset,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 type PersonsGetter interface {
 	GetPersons(filters *entity.Filters, limit, offset int64) ([]*entity.Person, error)
+	SeekPersons(ctx context.Context, filters *entity.Filters, after []interface{}, before bool, limit int64) ([]*entity.Person, error)
+	CountPersons(ctx context.Context, filters *entity.Filters) (int64, error)
 }
 
+// New lists persons matching the filter query parameters, see
+// filters.Schema. With limit and offset it pages by offset, otherwise by
+// the opaque cursors it returns, passed back as ?cursor=...&page_size=....
+// Either way the neighbouring pages are linked in the Link header.
 func New(log *slog.Logger, personsGetter PersonsGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.person.getall.New"
@@ -41,74 +56,204 @@ func New(log *slog.Logger, personsGetter PersonsGetter) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		var req Request
-
-		err := render.DecodeJSON(r.Body, &req)
-		if errors.Is(err, io.EOF) {
-			log.Error("request body is empty")
+		f, err := filters.FromQuery(r.URL.Query(), "limit", "offset", "page_size", "cursor", "with_total")
+		if err != nil {
+			log.Error("invalid filters", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("empty request"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
 
 			return
 		}
-		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("failed to decode request"))
+		var withTotal bool
+		if v := r.URL.Query().Get("with_total"); v != "" {
+			withTotal, err = strconv.ParseBool(v)
+			if err != nil {
+				log.Error("failed to convert with_total value", sl.Err(err))
 
-			return
-		}
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid with_total value"))
 
-		log.Info("request body decoded", slog.Any("request", req))
+				return
+			}
+		}
 
-		if err := validator.New().Struct(req); err != nil {
-			validateErr := err.(validator.ValidationErrors)
+		// Every parameter is checked before the count, which may be costly.
+		if !r.URL.Query().Has("limit") && !r.URL.Query().Has("offset") {
+			p, ok := parsePage(w, r, log, f)
+			if !ok {
+				return
+			}
 
-			log.Error("invalid request", sl.Err(err))
+			total, ok := countPersons(w, r, log, personsGetter, f, withTotal)
+			if !ok {
+				return
+			}
 
-			render.JSON(w, r, resp.ValidationError(validateErr))
+			getPage(w, r, log, personsGetter, f, p, total)
 
 			return
 		}
 
-		limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
-		if err != nil {
-			log.Error("failed to convert limit value", sl.Err(err))
+		limit, ok := params.Int(w, r, log, "limit", defaultPageSize, 1, maxPageSize)
+		if !ok {
+			return
+		}
 
-			render.JSON(w, r, resp.Error("invalid limit value"))
+		offset, ok := params.Int(w, r, log, "offset", 0, 0, -1)
+		if !ok {
+			return
+		}
 
+		total, ok := countPersons(w, r, log, personsGetter, f, withTotal)
+		if !ok {
 			return
 		}
 
-		offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+		persons, err := personsGetter.GetPersons(f, limit, offset)
 		if err != nil {
-			log.Error("failed to convert offset value", sl.Err(err))
+			log.Error("failed to get persons", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("invalid offset value"))
+			render.JSON(w, r, resp.Error("internal error"))
 
 			return
 		}
 
-		filters := &entity.Filters{
-			Name:       req.Name,
-			Surname:    req.Surname,
-			Patronymic: req.Patronymic,
-			Age:        req.Age,
-			Gender:     req.Gender,
-			Country:    req.Country,
-		}
+		log.Info("person successfully got")
 
-		persons, err := personsGetter.GetPersons(filters, limit, offset)
+		setOffsetLinks(w, r, limit, offset, int64(len(persons)), total)
 
-		log.Info("person successfully got")
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Persons:  persons,
+			Pagination: Pagination{
+				Total:  total,
+				Limit:  limit,
+				Offset: &offset,
+			},
+		})
+	}
+}
+
+// countPersons counts the matching persons if withTotal is set.
+func countPersons(w http.ResponseWriter, r *http.Request, log *slog.Logger, personsGetter PersonsGetter, f *entity.Filters, withTotal bool) (*int64, bool) {
+	if !withTotal {
+		return nil, true
+	}
+
+	count, err := personsGetter.CountPersons(r.Context(), f)
+	if err != nil {
+		log.Error("failed to count persons", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return nil, false
+	}
+
+	return &count, true
+}
+
+// page is a cursor mode request: the page size and the decoded cursor.
+type page struct {
+	size   int64
+	token  string
+	after  []interface{}
+	before bool
+}
+
+// parsePage reads page_size and cursor. On an invalid value it answers the
+// request with 400 and reports false.
+func parsePage(w http.ResponseWriter, r *http.Request, log *slog.Logger, f *entity.Filters) (page, bool) {
+	size, ok := params.Int(w, r, log, "page_size", defaultPageSize, 1, maxPageSize)
+	if !ok {
+		return page{}, false
+	}
+
+	p := page{size: size, token: r.URL.Query().Get("cursor")}
+	if p.token == "" {
+		return p, true
+	}
+
+	_, s, err := filters.ToQuery(f)
+	if err != nil {
+		log.Error("invalid filters", sl.Err(err))
 
-		responseOK(w, r, persons)
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error(err.Error()))
+
+		return page{}, false
 	}
+
+	c, err := query.DecodeCursor(p.token, s, filters.Fingerprint(f))
+	if err != nil {
+		log.Error("invalid cursor", sl.Err(err))
+
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("invalid cursor"))
+
+		return page{}, false
+	}
+	p.after, p.before = c.Values, c.Before
+
+	return p, true
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, persons []*entity.Person) {
+// getPage serves cursor mode. One row more than requested is read to tell
+// whether there is a further page in the direction of travel.
+func getPage(w http.ResponseWriter, r *http.Request, log *slog.Logger, personsGetter PersonsGetter, f *entity.Filters, p page, total *int64) {
+	after, before := p.after, p.before
+
+	persons, err := personsGetter.SeekPersons(r.Context(), f, after, before, p.size+1)
+	if err != nil {
+		log.Error("failed to get persons", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return
+	}
+
+	more := int64(len(persons)) > p.size
+	if more && before {
+		persons = persons[1:]
+	} else if more {
+		persons = persons[:p.size]
+	}
+
+	pagination := Pagination{
+		Total:  total,
+		Limit:  p.size,
+		Cursor: p.token,
+	}
+
+	if len(persons) > 0 {
+		// Going forward there is a previous page unless this is the first
+		// one; going back from a row there always is a next page. A before
+		// cursor without values is the last page.
+		if more || (before && after != nil) {
+			pagination.NextCursor = newCursor(f, persons[len(persons)-1], false)
+		}
+		if (more && before) || (after != nil && !before) {
+			pagination.PrevCursor = newCursor(f, persons[0], true)
+		}
+	}
+
+	log.Info("person page successfully got", slog.Int("count", len(persons)))
+
+	setCursorLinks(w, r, f, pagination)
+
 	render.JSON(w, r, Response{
-		Response: resp.OK(),
-		Persons:  persons,
+		Response:   resp.OK(),
+		Persons:    persons,
+		Pagination: pagination,
 	})
 }
+
+func newCursor(f *entity.Filters, p *entity.Person, before bool) string {
+	return query.Cursor{
+		Sort:   filters.SortParam(f),
+		Filter: filters.Fingerprint(f),
+		Values: filters.SortValues(p, f.Sort),
+		Before: before,
+	}.Encode()
+}
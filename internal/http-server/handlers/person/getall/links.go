@@ -0,0 +1,98 @@
+package getall
+
+import (
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/filters"
+	"person-extender/internal/lib/query"
+	"strconv"
+	"strings"
+)
+
+// link is one RFC 8288 link to a page of the current listing.
+type link struct {
+	rel   string
+	query map[string]string
+}
+
+func setOffsetLinks(w http.ResponseWriter, r *http.Request, limit, offset, count int64, total *int64) {
+	if limit < 1 {
+		return
+	}
+
+	page := func(rel string, offset int64) link {
+		return link{rel: rel, query: map[string]string{
+			"limit":  strconv.FormatInt(limit, 10),
+			"offset": strconv.FormatInt(offset, 10),
+		}}
+	}
+
+	links := []link{page("first", 0)}
+
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, page("prev", prev))
+	}
+
+	switch {
+	case total != nil:
+		if offset+count < *total {
+			links = append(links, page("next", offset+limit))
+		}
+
+		last := int64(0)
+		if *total > 0 {
+			last = (*total - 1) / limit * limit
+		}
+		links = append(links, page("last", last))
+	case count == limit:
+		links = append(links, page("next", offset+limit))
+	}
+
+	setLinks(w, r, links)
+}
+
+func setCursorLinks(w http.ResponseWriter, r *http.Request, f *entity.Filters, p Pagination) {
+	pageSize := strconv.FormatInt(p.Limit, 10)
+
+	links := []link{{rel: "first", query: map[string]string{"page_size": pageSize, "cursor": ""}}}
+
+	if p.PrevCursor != "" {
+		links = append(links, link{rel: "prev", query: map[string]string{"page_size": pageSize, "cursor": p.PrevCursor}})
+	}
+	if p.NextCursor != "" {
+		links = append(links, link{rel: "next", query: map[string]string{"page_size": pageSize, "cursor": p.NextCursor}})
+	}
+
+	last := query.Cursor{Sort: filters.SortParam(f), Filter: filters.Fingerprint(f), Before: true}.Encode()
+	links = append(links, link{rel: "last", query: map[string]string{"page_size": pageSize, "cursor": last}})
+
+	setLinks(w, r, links)
+}
+
+// setLinks writes the Link header. Each link keeps the filters and sort of
+// the request and overrides the paging parameters; empty ones are dropped.
+func setLinks(w http.ResponseWriter, r *http.Request, links []link) {
+	values := make([]string, 0, len(links))
+
+	for _, l := range links {
+		u := *r.URL
+
+		q := u.Query()
+		for key, v := range l.query {
+			if v == "" {
+				q.Del(key)
+			} else {
+				q.Set(key, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+
+		values = append(values, "<"+u.RequestURI()+`>; rel="`+l.rel+`"`)
+	}
+
+	w.Header().Set("Link", strings.Join(values, ", "))
+}
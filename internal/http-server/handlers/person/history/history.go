@@ -0,0 +1,236 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/etag"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
+)
+
+type Response struct {
+	resp.Response
+	History []*entity.HistoryEntry `json:"history"`
+}
+
+type HistoryGetter interface {
+	GetPersonHistory(ctx context.Context, ID int64) ([]*entity.HistoryEntry, error)
+}
+
+// New returns the change timeline of a person, oldest first.
+func New(log *slog.Logger, historyGetter HistoryGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.history.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		personID, ok := parseID(w, r, log)
+		if !ok {
+			return
+		}
+
+		history, err := historyGetter.GetPersonHistory(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get person history", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("person history successfully got", slog.Int64("id", personID), slog.Int("entries", len(history)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			History:  history,
+		})
+	}
+}
+
+type RevertRequest struct {
+	Version int64 `json:"version" validate:"required,gt=0"`
+}
+
+type RevertResponse struct {
+	resp.Response
+	Person *entity.Person `json:"person,omitempty"`
+}
+
+type PersonReverter interface {
+	RevertPerson(ctx context.Context, ID, version, expectedVersion int64) (*entity.Person, error)
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+// NewRevert restores the state a person had at the version in the body and
+// stores it as a new version. With If-Match the revert only applies if the
+// current version is one of the listed strong tags.
+func NewRevert(log *slog.Logger, personReverter PersonReverter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.history.NewRevert"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		personID, ok := parseID(w, r, log)
+		if !ok {
+			return
+		}
+
+		var req RevertRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.Error("invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		var versions []int64
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != "*" {
+			versions, ok = etag.ParseIfMatch(ifMatch)
+			if !ok {
+				log.Error("invalid If-Match header", slog.String("if_match", ifMatch))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid If-Match header"))
+
+				return
+			}
+		}
+
+		var expectedVersion int64
+		if len(versions) == 1 {
+			expectedVersion = versions[0]
+		} else {
+			current, err := personReverter.GetPerson(r.Context(), personID)
+			if errors.Is(err, storage.ErrPersonNotFound) {
+				log.Info("person not found", slog.Int64("id", personID))
+
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("person not found"))
+
+				return
+			}
+			if err != nil {
+				log.Error("failed to get person", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+
+			expectedVersion = current.Version
+			if len(versions) > 1 {
+				expectedVersion = etag.Expected(versions, current.Version)
+			}
+		}
+
+		person, err := personReverter.RevertPerson(r.Context(), personID, req.Version, expectedVersion)
+		switch {
+		case errors.Is(err, storage.ErrVersionConflict):
+			log.Info("version conflict", slog.Int64("current_version", person.Version))
+
+			w.Header().Set("ETag", etag.Format(person.Version))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, RevertResponse{
+				Response: resp.Error("version conflict"),
+				Person:   person,
+			})
+
+			return
+		case errors.Is(err, storage.ErrPersonNotFound):
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		case errors.Is(err, storage.ErrVersionNotFound):
+			log.Info("version not found", slog.Int64("id", personID), slog.Int64("version", req.Version))
+
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, resp.Error("version not found in history"))
+
+			return
+		case err != nil:
+			log.Error("failed to revert person", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("person successfully reverted", slog.Int64("id", personID), slog.Int64("to_version", req.Version))
+
+		w.Header().Set("ETag", etag.Format(person.Version))
+
+		render.JSON(w, r, RevertResponse{
+			Response: resp.OK(),
+			Person:   person,
+		})
+	}
+}
+
+func parseID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	ID := chi.URLParam(r, "id")
+	if ID == "" {
+		log.Error("ID is empty")
+
+		render.JSON(w, r, resp.Error("invalid request"))
+
+		return 0, false
+	}
+
+	personID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		log.Error("failed to convert ID to int64", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("invalid ID format"))
+
+		return 0, false
+	}
+
+	return personID, true
+}
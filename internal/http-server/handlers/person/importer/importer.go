@@ -0,0 +1,419 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+var ErrTooManyRows = errors.New("too many rows")
+
+// Duplicate policies, as for a single save: see save.DuplicatesAllow and its
+// siblings.
+const (
+	duplicatesAllow  = "allow"
+	duplicatesReject = "reject"
+)
+
+type Config struct {
+	MaxRows         int
+	Concurrency     int
+	BatchSize       int
+	DuplicatePolicy string
+}
+
+type Record struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	Surname    string `json:"surname" validate:"required,max=100"`
+	Patronymic string `json:"patronymic,omitempty" validate:"omitempty,max=100"`
+}
+
+// Row is the outcome of one input row. Duplicate rows carry the ID of the
+// person they repeat: an existing one or one imported from an earlier row.
+type Row struct {
+	Line             int    `json:"line"`
+	Status           string `json:"status"`
+	ID               int64  `json:"id,omitempty"`
+	EnrichmentStatus string `json:"enrichment_status,omitempty"`
+	Duplicate        bool   `json:"duplicate,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// Response counts the rows by outcome. Duplicates are the rows answered with
+// an existing person; rejected duplicates count as failed.
+type Response struct {
+	resp.Response
+	Total      int   `json:"total"`
+	Imported   int   `json:"imported"`
+	Duplicates int   `json:"duplicates"`
+	Failed     int   `json:"failed"`
+	Rows       []Row `json:"rows"`
+}
+
+type PersonsSaver interface {
+	SavePersons(ctx context.Context, persons []*entity.Person, batchSize int, unique bool) ([]int64, []error)
+}
+
+type PersonEnricher interface {
+	Enrich(ctx context.Context, name string) (*api.PersonExtends, error)
+	AllowsPartial() bool
+}
+
+type parsedRow struct {
+	line   int
+	record Record
+	err    error
+}
+
+// New imports persons from an NDJSON or CSV body. Each distinct name is
+// enriched once and the persons are inserted in batches; the response reports
+// the outcome of every input row. Unless cfg.DuplicatePolicy allows
+// duplicates, a row repeating a person is not inserted and either fails
+// (reject) or reports that person (return_existing).
+func New(log *slog.Logger, personEnricher PersonEnricher, personsSaver PersonsSaver, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.importer.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		format, err := detectFormat(r)
+		if err != nil {
+			log.Error("unsupported import format", sl.Err(err))
+
+			render.Status(r, http.StatusUnsupportedMediaType)
+			render.JSON(w, r, resp.Error("unsupported format, use text/csv or application/x-ndjson"))
+
+			return
+		}
+
+		// Reading and enriching a large import outlives the server-wide
+		// write timeout.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("failed to lift write deadline", sl.Err(err))
+		}
+
+		var rows []parsedRow
+		switch format {
+		case formatCSV:
+			rows, err = parseCSV(r.Body, cfg.MaxRows)
+		default:
+			rows, err = parseNDJSON(r.Body, cfg.MaxRows)
+		}
+		if errors.Is(err, ErrTooManyRows) {
+			log.Error("import is too large", slog.Int("max_rows", cfg.MaxRows))
+
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("at most %d rows can be imported at once", cfg.MaxRows)))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to read import", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("import decoded", slog.String("format", format), slog.Int("rows", len(rows)))
+
+		validate := validator.New()
+		for i := range rows {
+			if rows[i].err != nil {
+				continue
+			}
+			if err := validate.Struct(rows[i].record); err != nil {
+				rows[i].err = errors.New(resp.ValidationError(err.(validator.ValidationErrors)).Error)
+			}
+		}
+
+		extends := enrichNames(r.Context(), log, personEnricher, rows, cfg.Concurrency)
+
+		report := make([]Row, len(rows))
+		persons := make([]*entity.Person, 0, len(rows))
+		indices := make([]int, 0, len(rows))
+		enrichedAt := time.Now()
+
+		for i, row := range rows {
+			report[i] = Row{Line: row.line, Status: resp.StatusError}
+
+			if row.err != nil {
+				report[i].Error = row.err.Error()
+				continue
+			}
+
+			res := extends[api.NormalizeName(row.record.Name)]
+			if res.err != nil {
+				report[i].Error = "enrichment failed"
+				continue
+			}
+
+			person := &entity.Person{
+				Name:       row.record.Name,
+				Surname:    row.record.Surname,
+				Patronymic: row.record.Patronymic,
+				EnrichedAt: &enrichedAt,
+			}
+			res.personExtends.Apply(person)
+
+			persons = append(persons, person)
+			indices = append(indices, i)
+		}
+
+		ids, errs := personsSaver.SavePersons(r.Context(), persons, cfg.BatchSize, cfg.DuplicatePolicy != duplicatesAllow)
+
+		var imported, duplicates int
+		for j, i := range indices {
+			if errors.Is(errs[j], storage.ErrDuplicatePerson) {
+				report[i].ID = ids[j]
+				report[i].Duplicate = true
+
+				if cfg.DuplicatePolicy == duplicatesReject {
+					report[i].Error = "duplicate person"
+					continue
+				}
+
+				report[i].Status = resp.StatusOK
+				duplicates++
+				continue
+			}
+			if errs[j] != nil {
+				log.Error("failed to save imported person", slog.Int("line", rows[i].line), sl.Err(errs[j]))
+
+				report[i].Error = "internal error"
+				continue
+			}
+
+			report[i].Status = resp.StatusOK
+			report[i].ID = ids[j]
+			report[i].EnrichmentStatus = persons[j].EnrichmentStatus
+			imported++
+		}
+
+		failed := len(rows) - imported - duplicates
+
+		log.Info("import finished", slog.Int("imported", imported), slog.Int("duplicates", duplicates), slog.Int("failed", failed))
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Total:      len(rows),
+			Imported:   imported,
+			Duplicates: duplicates,
+			Failed:     failed,
+			Rows:       report,
+		})
+	}
+}
+
+func detectFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		switch f {
+		case formatCSV, formatNDJSON:
+			return f, nil
+		}
+
+		return "", fmt.Errorf("unknown format %q", f)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return formatCSV, nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return formatNDJSON, nil
+	}
+
+	return "", fmt.Errorf("unsupported content type %q", mediaType)
+}
+
+func parseNDJSON(body io.Reader, maxRows int) ([]parsedRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []parsedRow
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+
+		row := parsedRow{line: line}
+		if err := json.Unmarshal([]byte(text), &row.record); err != nil {
+			row.err = errors.New("invalid JSON")
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// parseCSV expects a header row naming the name, surname and, optionally,
+// patronymic columns in any order.
+func parseCSV(body io.Reader, maxRows int) ([]parsedRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	for _, required := range []string{"name", "surname"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []parsedRow
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if len(rows) == maxRows {
+			return nil, ErrTooManyRows
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, parsedRow{line: parseErr.StartLine, err: errors.New("invalid CSV row")})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		rows = append(rows, parsedRow{
+			line: line,
+			record: Record{
+				Name:       field(record, "name"),
+				Surname:    field(record, "surname"),
+				Patronymic: field(record, "patronymic"),
+			},
+		})
+	}
+
+	return rows, nil
+}
+
+type enrichResult struct {
+	personExtends *api.PersonExtends
+	err           error
+}
+
+// enrichNames looks up every distinct normalized name of the valid rows once,
+// with at most concurrency lookups in flight.
+func enrichNames(ctx context.Context, log *slog.Logger, personEnricher PersonEnricher, rows []parsedRow, concurrency int) map[string]enrichResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	names := make(map[string]string)
+	for _, row := range rows {
+		if row.err != nil {
+			continue
+		}
+
+		key := api.NormalizeName(row.record.Name)
+		if _, ok := names[key]; !ok {
+			names[key] = row.record.Name
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, concurrency)
+		results = make(map[string]enrichResult, len(names))
+	)
+
+	for key, name := range names {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(key, name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			personExtends, err := personEnricher.Enrich(ctx, name)
+			if err != nil {
+				var enrichErr *api.EnrichError
+				if !errors.As(err, &enrichErr) || !personEnricher.AllowsPartial() {
+					log.Warn("failed to get persons extends", slog.String("name", name), sl.Err(err))
+				} else {
+					err = nil
+				}
+			}
+
+			mu.Lock()
+			results[key] = enrichResult{personExtends: personExtends, err: err}
+			mu.Unlock()
+		}(key, name)
+	}
+
+	wg.Wait()
+
+	return results
+}
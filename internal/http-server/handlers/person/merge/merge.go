@@ -0,0 +1,109 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/etag"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+)
+
+type Request struct {
+	TargetID  int64   `json:"target_id" validate:"required,gt=0"`
+	SourceIDs []int64 `json:"source_ids" validate:"required,min=1,max=100,unique,dive,gt=0"`
+}
+
+type Response struct {
+	resp.Response
+	Person *entity.Person `json:"person,omitempty"`
+}
+
+type PersonsMerger interface {
+	MergePersons(ctx context.Context, targetID int64, sourceIDs []int64) (*entity.Person, error)
+}
+
+// New merges the source persons into the target. Fields the target lacks are
+// taken from the sources, which are then deleted and point to the target.
+func New(log *slog.Logger, personsMerger PersonsMerger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.merge.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+
+			log.Error("invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.ValidationError(validateErr))
+
+			return
+		}
+
+		for _, ID := range req.SourceIDs {
+			if ID == req.TargetID {
+				log.Error("target is among the sources", slog.Int64("id", ID))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("field source_ids must not contain target_id"))
+
+				return
+			}
+		}
+
+		person, err := personsMerger.MergePersons(r.Context(), req.TargetID, req.SourceIDs)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", sl.Err(err))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to merge persons", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("persons successfully merged", slog.Int64("target_id", req.TargetID), slog.Any("source_ids", req.SourceIDs))
+
+		w.Header().Set("ETag", etag.Format(person.Version))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Person:   person,
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package purge
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/lib/api/params"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"time"
+)
+
+// maxOlderThanDays keeps the cutoff well within what time.Duration can hold
+// (about 106751 days).
+const maxOlderThanDays = 36500
+
+type Response struct {
+	resp.Response
+	Purged int64 `json:"purged"`
+}
+
+type PersonPurger interface {
+	PurgeDeletedPersons(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// New hard deletes persons soft deleted more than ?older_than_days=N days
+// ago, or longer ago than retention if the parameter is missing.
+func New(log *slog.Logger, personPurger PersonPurger, retention time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.purge.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		olderThan := retention
+		if r.URL.Query().Get("older_than_days") != "" {
+			days, ok := params.Int(w, r, log, "older_than_days", 0, 0, maxOlderThanDays)
+			if !ok {
+				return
+			}
+			olderThan = time.Duration(days) * 24 * time.Hour
+		}
+
+		purged, err := personPurger.PurgeDeletedPersons(r.Context(), time.Now().Add(-olderThan))
+		if err != nil {
+			log.Error("failed to purge deleted persons", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("deleted persons successfully purged", slog.Int64("purged", purged))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Purged:   purged,
+		})
+	}
+}
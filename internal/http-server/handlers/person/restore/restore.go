@@ -0,0 +1,89 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/etag"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
+)
+
+type Response struct {
+	resp.Response
+	Person *entity.Person `json:"person,omitempty"`
+}
+
+type PersonRestorer interface {
+	RestorePerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+func New(log *slog.Logger, personRestorer PersonRestorer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.restore.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		personID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
+		person, err := personRestorer.RestorePerson(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrNotDeleted) {
+			log.Info("person is not deleted", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("person is not deleted"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to restore person", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("person successfully restored", slog.Int64("id", personID))
+
+		w.Header().Set("ETag", etag.Format(person.Version))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Person:   person,
+		})
+	}
+}
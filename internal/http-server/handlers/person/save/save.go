@@ -1,7 +1,9 @@
 package save
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
@@ -12,6 +14,9 @@ import (
 	"person-extender/internal/lib/api"
 	resp "person-extender/internal/lib/api/response"
 	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strings"
+	"time"
 )
 
 type Request struct {
@@ -22,14 +27,42 @@ type Request struct {
 
 type Response struct {
 	resp.Response
-	ID int64 `json:"id"`
+	ID               int64    `json:"id"`
+	JobID            int64    `json:"job_id,omitempty"`
+	EnrichmentStatus string   `json:"enrichment_status"`
+	Pending          []string `json:"pending,omitempty"`
+	Duplicate        bool     `json:"duplicate,omitempty"`
 }
 
+// Duplicate policies, deciding what happens when a person with the same
+// normalized name already exists.
+const (
+	DuplicatesAllow          = "allow"
+	DuplicatesReject         = "reject"
+	DuplicatesReturnExisting = "return_existing"
+)
+
 type PersonSaver interface {
-	SavePerson(person *entity.Person) (int64, error)
+	SavePerson(ctx context.Context, person *entity.Person) (int64, error)
+	SaveUniquePerson(ctx context.Context, person *entity.Person) (*entity.Person, error)
+	FindDuplicate(ctx context.Context, person *entity.Person) (*entity.Person, error)
 }
 
-func New(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
+type PersonEnricher interface {
+	Enrich(ctx context.Context, name string) (*api.PersonExtends, error)
+	AllowsPartial() bool
+}
+
+type JobEnqueuer interface {
+	Enqueue(ctx context.Context, personID int64) (*entity.Job, error)
+}
+
+// New creates persons. When jobEnqueuer is set and the client sends
+// "Prefer: respond-async", the person is stored right away and enriched by a
+// background job; the response is 202 with the job ID. Duplicates are
+// checked before enrichment according to duplicatePolicy, and again when the
+// person is stored.
+func New(log *slog.Logger, personEnricher PersonEnricher, personSaver PersonSaver, jobEnqueuer JobEnqueuer, duplicatePolicy string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.person.save.New"
 
@@ -68,25 +101,46 @@ func New(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
 			return
 		}
 
-		personExtends, err := api.GetPersonExtends(req.Name)
-		if err != nil {
-			log.Error("failed to get persons extends", sl.Err(err))
+		if duplicatePolicy != DuplicatesAllow && handleDuplicate(w, r, log, req, personSaver, duplicatePolicy) {
+			return
+		}
 
-			render.JSON(w, r, resp.Error("internal error"))
+		if jobEnqueuer != nil && preferAsync(r) {
+			saveAsync(w, r, log, req, personSaver, jobEnqueuer, duplicatePolicy)
 
 			return
 		}
 
+		personExtends, err := personEnricher.Enrich(r.Context(), req.Name)
+		if err != nil {
+			var enrichErr *api.EnrichError
+			if !errors.As(err, &enrichErr) || !personEnricher.AllowsPartial() {
+				log.Error("failed to get persons extends", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			}
+
+			log.Warn("person extends are incomplete", sl.Err(err))
+		}
+
+		enrichedAt := time.Now()
+
 		person := &entity.Person{
 			Name:       req.Name,
 			Surname:    req.Surname,
 			Patronymic: req.Patronymic,
-			Age:        personExtends.Age,
-			Gender:     personExtends.Gender,
-			Country:    personExtends.Country,
+			EnrichedAt: &enrichedAt,
 		}
+		personExtends.Apply(person)
+
+		saved, err := savePerson(r.Context(), personSaver, person, duplicatePolicy)
+		if errors.Is(err, storage.ErrDuplicatePerson) {
+			respondDuplicate(w, r, log, saved, duplicatePolicy)
 
-		ID, err := personSaver.SavePerson(person)
+			return
+		}
 		if err != nil {
 			log.Error("failed to save person", sl.Err(err))
 
@@ -95,15 +149,148 @@ func New(log *slog.Logger, personSaver PersonSaver) http.HandlerFunc {
 			return
 		}
 
-		log.Info("person successfully added", slog.Int64("id", ID))
+		log.Info("person successfully added", slog.Int64("id", saved.ID))
 
-		responseOK(w, r, ID)
+		responseOK(w, r, saved.ID, person)
 	}
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, ID int64) {
+// savePerson stores person. Unless duplicatePolicy allows duplicates, it
+// fails with storage.ErrDuplicatePerson and the existing person if one was
+// created since handleDuplicate looked.
+func savePerson(ctx context.Context, personSaver PersonSaver, person *entity.Person, duplicatePolicy string) (*entity.Person, error) {
+	if duplicatePolicy != DuplicatesAllow {
+		return personSaver.SaveUniquePerson(ctx, person)
+	}
+
+	ID, err := personSaver.SavePerson(ctx, person)
+	if err != nil {
+		return nil, err
+	}
+	person.ID = ID
+
+	return person, nil
+}
+
+func responseOK(w http.ResponseWriter, r *http.Request, ID int64, person *entity.Person) {
 	render.JSON(w, r, Response{
-		Response: resp.OK(),
-		ID:       ID,
+		Response:         resp.OK(),
+		ID:               ID,
+		EnrichmentStatus: person.EnrichmentStatus,
+		Pending:          person.PendingFields(),
 	})
 }
+
+func saveAsync(w http.ResponseWriter, r *http.Request, log *slog.Logger, req Request, personSaver PersonSaver, jobEnqueuer JobEnqueuer, duplicatePolicy string) {
+	person := &entity.Person{
+		Name:             req.Name,
+		Surname:          req.Surname,
+		Patronymic:       req.Patronymic,
+		EnrichmentStatus: entity.EnrichmentPending,
+	}
+
+	saved, err := savePerson(r.Context(), personSaver, person, duplicatePolicy)
+	if errors.Is(err, storage.ErrDuplicatePerson) {
+		respondDuplicate(w, r, log, saved, duplicatePolicy)
+
+		return
+	}
+	if err != nil {
+		log.Error("failed to save person", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return
+	}
+	ID := saved.ID
+
+	job, err := jobEnqueuer.Enqueue(r.Context(), ID)
+	if err != nil {
+		// The person is already stored as pending and will be picked up by
+		// the re-enrichment worker, so report it like a partial save.
+		log.Error("failed to enqueue enrichment job", slog.Int64("id", ID), sl.Err(err))
+
+		responseOK(w, r, ID, person)
+
+		return
+	}
+
+	log.Info("person added, enrichment queued", slog.Int64("id", ID), slog.Int64("job_id", job.ID))
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	w.Header().Set("Preference-Applied", "respond-async")
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, Response{
+		Response:         resp.OK(),
+		ID:               ID,
+		JobID:            job.ID,
+		EnrichmentStatus: person.EnrichmentStatus,
+		Pending:          person.PendingFields(),
+	})
+}
+
+// handleDuplicate answers the request if a duplicate of req exists and
+// reports whether it did.
+func handleDuplicate(w http.ResponseWriter, r *http.Request, log *slog.Logger, req Request, personSaver PersonSaver, duplicatePolicy string) bool {
+	existing, err := personSaver.FindDuplicate(r.Context(), &entity.Person{
+		Name:       req.Name,
+		Surname:    req.Surname,
+		Patronymic: req.Patronymic,
+	})
+	if errors.Is(err, storage.ErrPersonNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Error("failed to look up duplicates", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+
+		return true
+	}
+
+	respondDuplicate(w, r, log, existing, duplicatePolicy)
+
+	return true
+}
+
+// respondDuplicate rejects the request or returns existing, according to
+// duplicatePolicy.
+func respondDuplicate(w http.ResponseWriter, r *http.Request, log *slog.Logger, existing *entity.Person, duplicatePolicy string) {
+	response := Response{
+		Response:         resp.OK(),
+		ID:               existing.ID,
+		EnrichmentStatus: existing.EnrichmentStatus,
+		Pending:          existing.PendingFields(),
+		Duplicate:        true,
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/persons/%d", existing.ID))
+
+	if duplicatePolicy == DuplicatesReject {
+		log.Info("duplicate person rejected", slog.Int64("existing_id", existing.ID))
+
+		response.Response = resp.Error("duplicate person")
+
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, response)
+
+		return
+	}
+
+	log.Info("existing person returned", slog.Int64("id", existing.ID))
+
+	render.JSON(w, r, response)
+}
+
+func preferAsync(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/params"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	maxQueryLen  = 200
+)
+
+type Response struct {
+	resp.Response
+	Persons []*entity.PersonMatch `json:"persons"`
+}
+
+type PersonsSearcher interface {
+	SearchPersons(ctx context.Context, q string, limit, offset int64) ([]*entity.PersonMatch, error)
+}
+
+// New searches persons by name with ?q=, tolerating misspellings. Results
+// are ranked by relevance and carry their score.
+func New(log *slog.Logger, personsSearcher PersonsSearcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.search.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" || utf8.RuneCountInString(q) > maxQueryLen {
+			log.Error("invalid search query", slog.String("q", q))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid q value"))
+
+			return
+		}
+
+		limit, ok := params.Int(w, r, log, "limit", defaultLimit, 1, maxLimit)
+		if !ok {
+			return
+		}
+
+		offset, ok := params.Int(w, r, log, "offset", 0, 0, -1)
+		if !ok {
+			return
+		}
+
+		matches, err := personsSearcher.SearchPersons(r.Context(), q, limit, offset)
+		if err != nil {
+			log.Error("failed to search persons", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("persons successfully searched", slog.String("q", q), slog.Int("found", len(matches)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Persons:  matches,
+		})
+	}
+}
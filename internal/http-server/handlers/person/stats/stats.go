@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api/filters"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultTopCountries = 10
+	maxTopCountries     = 100
+	maxAgeBuckets       = 50
+)
+
+var defaultAgeBuckets = []int64{0, 18, 30, 45, 60}
+
+type Response struct {
+	resp.Response
+	Total     int64                 `json:"total"`
+	Ages      Ages                  `json:"ages"`
+	Genders   []*entity.GenderStat  `json:"genders"`
+	Countries []*entity.CountryStat `json:"countries"`
+}
+
+type Ages struct {
+	Buckets []*entity.AgeBucket `json:"buckets"`
+	Unknown int64               `json:"unknown"`
+}
+
+type StatsGetter interface {
+	PersonStats(ctx context.Context, filters *entity.Filters, bounds []int64, topCountries int64) (*entity.PersonStats, error)
+}
+
+// New aggregates the persons matching the listing filters, see
+// filters.Schema. ?age_buckets=0,18,65 sets the ascending lower bounds of the
+// age histogram and ?top_countries=N how many countries are reported.
+func New(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.stats.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		f, err := filters.FromQuery(r.URL.Query(), "age_buckets", "top_countries")
+		if err != nil {
+			log.Error("invalid filters", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		bounds, ok := parseBuckets(r.URL.Query().Get("age_buckets"))
+		if !ok {
+			log.Error("invalid age_buckets value", slog.String("age_buckets", r.URL.Query().Get("age_buckets")))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid age_buckets value"))
+
+			return
+		}
+
+		top := int64(defaultTopCountries)
+		if v := r.URL.Query().Get("top_countries"); v != "" {
+			top, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || top < 1 || top > maxTopCountries {
+				log.Error("invalid top_countries value", slog.String("top_countries", v))
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid top_countries value"))
+
+				return
+			}
+		}
+
+		stats, err := statsGetter.PersonStats(r.Context(), f, bounds, top)
+		if err != nil {
+			log.Error("failed to compute stats", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("stats successfully computed", slog.Int64("total", stats.Total))
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Total:     stats.Total,
+			Ages:      Ages{Buckets: stats.Ages, Unknown: stats.UnknownAge},
+			Genders:   stats.Genders,
+			Countries: stats.Countries,
+		})
+	}
+}
+
+// parseBuckets reads comma separated, strictly ascending, non-negative
+// bounds, or returns the default ones for an empty value.
+func parseBuckets(v string) ([]int64, bool) {
+	if v == "" {
+		return defaultAgeBuckets, true
+	}
+
+	parts := strings.Split(v, ",")
+	if len(parts) > maxAgeBuckets {
+		return nil, false
+	}
+
+	bounds := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		bound, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || bound < 0 || (len(bounds) > 0 && bound <= bounds[len(bounds)-1]) {
+			return nil, false
+		}
+		bounds = append(bounds, bound)
+	}
+
+	return bounds, true
+}
@@ -0,0 +1,430 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/jsonpatch"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+
+	maxPatchSize = 64 * 1024
+)
+
+type PersonPatcher interface {
+	PatchPerson(ctx context.Context, ID int64, fields map[string]interface{}, expectedVersion int64, reset bool) (*entity.Person, error)
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+// patchDocument is the JSON document patches are applied to.
+type patchDocument struct {
+	Name       string  `json:"name"`
+	Surname    string  `json:"surname"`
+	Patronymic *string `json:"patronymic"`
+	Age        *int64  `json:"age"`
+	Gender     *string `json:"gender"`
+	Country    *string `json:"country"`
+	Version    int64   `json:"version"`
+}
+
+// NewPatch partially updates a person with an RFC 7396 merge patch
+// (application/merge-patch+json, also assumed for application/json) or an
+// RFC 6902 JSON Patch (application/json-patch+json). Only changed columns
+// are written. The expected version comes from If-Match, the version member
+// of a merge patch or a JSON Patch "test" of /version. Re-enrichment follows
+// the same rules as for New.
+func NewPatch(log *slog.Logger, personPatcher PersonPatcher, personRefresher PersonRefresher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.person.update.NewPatch"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		personID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (mediaType != mediaTypeMergePatch && mediaType != mediaTypeJSONPatch && mediaType != "application/json") {
+			log.Error("unsupported patch media type", slog.String("content_type", r.Header.Get("Content-Type")))
+
+			w.Header().Set("Accept-Patch", mediaTypeMergePatch+", "+mediaTypeJSONPatch)
+			render.Status(r, http.StatusUnsupportedMediaType)
+			render.JSON(w, r, resp.Error("unsupported patch format"))
+
+			return
+		}
+
+		forceReenrich, err := reenrichRequested(r)
+		if err != nil {
+			log.Error("invalid reenrich parameter", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid reenrich parameter"))
+
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPatchSize))
+		if err != nil {
+			log.Error("failed to read request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+		if len(body) == 0 {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+
+		current, err := personPatcher.GetPerson(r.Context(), personID)
+		if errors.Is(err, storage.ErrPersonNotFound) {
+			log.Info("person not found", slog.Int64("id", personID))
+
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("person not found"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to get person", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		doc, err := toDocument(current)
+		if err != nil {
+			log.Error("failed to build patch document", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		var (
+			patched     map[string]interface{}
+			bodyVersion *int64
+		)
+
+		if mediaType == mediaTypeJSONPatch {
+			patched, bodyVersion, err = applyJSONPatch(doc, body, current.Version)
+		} else {
+			patched, bodyVersion, err = applyMergePatch(doc, body)
+		}
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			log.Info("patch test failed", sl.Err(err))
+
+			writeUpdateError(w, r, log, current, storage.ErrVersionConflict)
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to apply patch", sl.Err(err))
+
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		fields, err := changedFields(doc, patched)
+		if err != nil {
+			log.Error("invalid patch", sl.Err(err))
+
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		log.Info("patch applied", slog.Any("fields", fields))
+
+		expectedVersion, err := resolveVersion(r, bodyVersion, func() (*entity.Person, error) {
+			return current, nil
+		})
+		if err != nil {
+			writePreconditionError(w, r, log, err)
+
+			return
+		}
+
+		if len(fields) == 0 {
+			if expectedVersion != current.Version {
+				writeUpdateError(w, r, log, current, storage.ErrVersionConflict)
+
+				return
+			}
+
+			log.Info("patch changes nothing", slog.Int64("id", personID))
+
+			var job *entity.Job
+			if forceReenrich {
+				current, job = refresh(r.Context(), log, personRefresher, current)
+			}
+
+			responseOK(w, r, current, job)
+
+			return
+		}
+
+		nameChanged := false
+		if name, ok := fields["name"].(string); ok {
+			nameChanged = api.NormalizeName(name) != api.NormalizeName(current.Name)
+		}
+
+		updated, err := personPatcher.PatchPerson(r.Context(), personID, fields, expectedVersion, nameChanged)
+		if !writeUpdateError(w, r, log, updated, err) {
+			return
+		}
+
+		log.Info("person successfully patched", slog.Int64("id", personID), slog.Int64("version", updated.Version))
+
+		var job *entity.Job
+		if nameChanged || forceReenrich {
+			updated, job = refresh(r.Context(), log, personRefresher, updated)
+		}
+
+		responseOK(w, r, updated, job)
+	}
+}
+
+func toDocument(p *entity.Person) (map[string]interface{}, error) {
+	d := patchDocument{
+		Name:    p.Name,
+		Surname: p.Surname,
+		Age:     p.Age,
+		Gender:  p.Gender,
+		Country: p.Country,
+		Version: p.Version,
+	}
+	if p.Patronymic != "" {
+		d.Patronymic = &p.Patronymic
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// applyMergePatch strips the version member, which states the expected
+// version rather than a new value, and merges the rest.
+func applyMergePatch(doc map[string]interface{}, body []byte) (map[string]interface{}, *int64, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, nil, errors.New("merge patch must be a JSON object")
+	}
+
+	var bodyVersion *int64
+	if v, ok := patch["version"]; ok {
+		version, ok := toInt64(v)
+		if !ok {
+			return nil, nil, errors.New("field version is not valid")
+		}
+		bodyVersion = &version
+
+		delete(patch, "version")
+	}
+
+	patched, _ := jsonpatch.Merge(doc, patch).(map[string]interface{})
+
+	return patched, bodyVersion, nil
+}
+
+// applyJSONPatch treats a successful "test" of /version as the expected
+// version, which is then the version the document was built from.
+func applyJSONPatch(doc map[string]interface{}, body []byte, version int64) (map[string]interface{}, *int64, error) {
+	var ops []jsonpatch.Operation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, nil, errors.New("JSON patch must be an array of operations")
+	}
+
+	res, err := jsonpatch.Apply(doc, ops)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patched, ok := res.(map[string]interface{})
+	if !ok {
+		return nil, nil, errors.New("patched person must be a JSON object")
+	}
+
+	var bodyVersion *int64
+	for _, op := range ops {
+		if op.Op == "test" && op.Path == "/version" {
+			bodyVersion = &version
+		}
+	}
+
+	return patched, bodyVersion, nil
+}
+
+// changedFields validates every member that differs between doc and patched
+// and returns the new column values.
+func changedFields(doc, patched map[string]interface{}) (map[string]interface{}, error) {
+	keys := make(map[string]bool, len(doc)+len(patched))
+	for k := range doc {
+		keys[k] = true
+	}
+	for k := range patched {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	fields := make(map[string]interface{})
+
+	for _, k := range sorted {
+		if reflect.DeepEqual(doc[k], patched[k]) {
+			continue
+		}
+
+		validate, ok := fieldValidators[k]
+		if !ok {
+			return nil, fmt.Errorf("field %s cannot be patched", k)
+		}
+
+		v, err := validate(k, patched[k])
+		if err != nil {
+			return nil, err
+		}
+
+		fields[k] = v
+	}
+
+	return fields, nil
+}
+
+var fieldValidators = map[string]func(field string, v interface{}) (interface{}, error){
+	"name":       requiredString(100),
+	"surname":    requiredString(100),
+	"patronymic": optionalString(100),
+	"age":        optionalInt(0, 150),
+	"gender":     optionalOneOf("male", "female"),
+	"country":    optionalString(5),
+}
+
+func requiredString(maxLen int) func(string, interface{}) (interface{}, error) {
+	return func(field string, v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, fmt.Errorf("field %s is a required field", field)
+		}
+		if utf8.RuneCountInString(s) > maxLen {
+			return nil, fmt.Errorf("field %s is not valid", field)
+		}
+
+		return s, nil
+	}
+}
+
+func optionalString(maxLen int) func(string, interface{}) (interface{}, error) {
+	return func(field string, v interface{}) (interface{}, error) {
+		if v == nil {
+			return nil, nil
+		}
+
+		s, ok := v.(string)
+		if !ok || s == "" || utf8.RuneCountInString(s) > maxLen {
+			return nil, fmt.Errorf("field %s is not valid", field)
+		}
+
+		return s, nil
+	}
+}
+
+func optionalOneOf(values ...string) func(string, interface{}) (interface{}, error) {
+	return func(field string, v interface{}) (interface{}, error) {
+		if v == nil {
+			return nil, nil
+		}
+
+		s, _ := v.(string)
+		for _, allowed := range values {
+			if s == allowed {
+				return s, nil
+			}
+		}
+
+		return nil, fmt.Errorf("field %s is not valid", field)
+	}
+}
+
+func optionalInt(min, max int64) func(string, interface{}) (interface{}, error) {
+	return func(field string, v interface{}) (interface{}, error) {
+		if v == nil {
+			return nil, nil
+		}
+
+		i, ok := toInt64(v)
+		if !ok || i < min || i > max {
+			return nil, fmt.Errorf("field %s is not valid", field)
+		}
+
+		return i, nil
+	}
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+
+	return int64(f), true
+}
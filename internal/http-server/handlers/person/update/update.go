@@ -1,7 +1,10 @@
 package update
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
@@ -9,30 +12,75 @@ import (
 	"log/slog"
 	"net/http"
 	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	"person-extender/internal/lib/api/etag"
 	resp "person-extender/internal/lib/api/response"
 	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"strconv"
 )
 
 type Request struct {
-	Person *entity.Person
+	Name       string  `json:"name" validate:"required"`
+	Surname    string  `json:"surname" validate:"required"`
+	Patronymic string  `json:"patronymic,omitempty"`
+	Age        *int64  `json:"age"`
+	Gender     *string `json:"gender"`
+	Country    *string `json:"country"`
+	Version    *int64  `json:"version,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Person *entity.Person `json:"person,omitempty"`
+	JobID  int64          `json:"job_id,omitempty"`
 }
 
 type PersonUpdater interface {
-	UpdatePerson(person *entity.Person) error
+	UpdatePerson(ctx context.Context, person *entity.Person, expectedVersion int64, reset bool) (*entity.Person, error)
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+}
+
+// PersonRefresher re-runs enrichment for a person, either inline, returning
+// the enriched person, or by queueing a job.
+type PersonRefresher interface {
+	Refresh(ctx context.Context, ID int64) (*entity.Person, *entity.Job, error)
 }
 
-func New(log *slog.Logger, personUpdater PersonUpdater) http.HandlerFunc {
+// New replaces a person. The expected version comes from If-Match or, failing
+// that, the version field of the body; a stale version is answered with 409
+// and the current representation. A changed name drops the enriched fields
+// and re-enriches the person, as does ?reenrich=true.
+func New(log *slog.Logger, personUpdater PersonUpdater, personRefresher PersonRefresher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.person.save.New"
+		const op = "handlers.person.update.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		ID := chi.URLParam(r, "id")
+		if ID == "" {
+			log.Error("ID is empty")
+
+			render.JSON(w, r, resp.Error("invalid request"))
+
+			return
+		}
+
+		personID, err := strconv.ParseInt(ID, 10, 64)
+		if err != nil {
+			log.Error("failed to convert ID to int64", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid ID format"))
+
+			return
+		}
+
 		var req Request
 
-		err := render.DecodeJSON(r.Body, &req)
+		err = render.DecodeJSON(r.Body, &req)
 		if errors.Is(err, io.EOF) {
 			log.Error("request body is empty")
 
@@ -59,16 +107,223 @@ func New(log *slog.Logger, personUpdater PersonUpdater) http.HandlerFunc {
 
 			return
 		}
+		if err := validateFields(req); err != nil {
+			log.Error("invalid request", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		forceReenrich, err := reenrichRequested(r)
+		if err != nil {
+			log.Error("invalid reenrich parameter", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid reenrich parameter"))
+
+			return
+		}
+
+		current, err := personUpdater.GetPerson(r.Context(), personID)
+		if err != nil {
+			writePreconditionError(w, r, log, err)
+
+			return
+		}
 
-		err = personUpdater.UpdatePerson(req.Person)
+		expectedVersion, err := resolveVersion(r, req.Version, func() (*entity.Person, error) {
+			return current, nil
+		})
 		if err != nil {
-			log.Error("failed to update person", sl.Err(err))
+			writePreconditionError(w, r, log, err)
+
+			return
+		}
+
+		nameChanged := api.NormalizeName(current.Name) != api.NormalizeName(req.Name)
 
-			render.JSON(w, r, resp.Error("internal error"))
+		person := &entity.Person{
+			ID:         personID,
+			Name:       req.Name,
+			Surname:    req.Surname,
+			Patronymic: req.Patronymic,
+			Age:        req.Age,
+			Gender:     req.Gender,
+			Country:    req.Country,
+		}
 
+		updated, err := personUpdater.UpdatePerson(r.Context(), person, expectedVersion, nameChanged)
+		if !writeUpdateError(w, r, log, updated, err) {
 			return
 		}
 
-		log.Info("person successfully updated")
+		log.Info("person successfully updated", slog.Int64("id", personID), slog.Int64("version", updated.Version))
+
+		var job *entity.Job
+		if nameChanged || forceReenrich {
+			updated, job = refresh(r.Context(), log, personRefresher, updated)
+		}
+
+		responseOK(w, r, updated, job)
+	}
+}
+
+func reenrichRequested(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("reenrich")
+	if v == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(v)
+}
+
+// refresh re-enriches an already written person. A failure is only logged:
+// the write stands and the person stays pending for the background worker.
+func refresh(ctx context.Context, log *slog.Logger, personRefresher PersonRefresher, person *entity.Person) (*entity.Person, *entity.Job) {
+	refreshed, job, err := personRefresher.Refresh(ctx, person.ID)
+	if err != nil {
+		log.Error("failed to re-enrich person", slog.Int64("id", person.ID), sl.Err(err))
+
+		return person, nil
+	}
+
+	if job != nil {
+		log.Info("re-enrichment queued", slog.Int64("id", person.ID), slog.Int64("job_id", job.ID))
+
+		return person, job
+	}
+
+	log.Info("person re-enriched", slog.Int64("id", person.ID), slog.String("enrichment_status", refreshed.EnrichmentStatus))
+
+	return refreshed, nil
+}
+
+var (
+	errPreconditionRequired = errors.New("version is required, send If-Match or a version field")
+	errInvalidIfMatch       = errors.New("invalid If-Match header")
+)
+
+// resolveVersion resolves the version a write is conditioned on. If-Match
+// wins over the body version; "If-Match: *" accepts whatever version is
+// current, which current looks up, as does a list of several tags.
+func resolveVersion(r *http.Request, bodyVersion *int64, current func() (*entity.Person, error)) (int64, error) {
+	ifMatch := r.Header.Get("If-Match")
+
+	switch {
+	case ifMatch == "*":
+		person, err := current()
+		if err != nil {
+			return 0, err
+		}
+
+		return person.Version, nil
+	case ifMatch != "":
+		versions, ok := etag.ParseIfMatch(ifMatch)
+		if !ok {
+			return 0, errInvalidIfMatch
+		}
+		if len(versions) == 1 {
+			return versions[0], nil
+		}
+
+		person, err := current()
+		if err != nil {
+			return 0, err
+		}
+
+		return etag.Expected(versions, person.Version), nil
+	case bodyVersion != nil:
+		return *bodyVersion, nil
+	default:
+		return 0, errPreconditionRequired
+	}
+}
+
+func writePreconditionError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, errPreconditionRequired):
+		log.Error("version precondition is missing")
+
+		render.Status(r, http.StatusPreconditionRequired)
+		render.JSON(w, r, resp.Error(err.Error()))
+	case errors.Is(err, errInvalidIfMatch):
+		log.Error("invalid If-Match header", slog.String("if_match", r.Header.Get("If-Match")))
+
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error(err.Error()))
+	case errors.Is(err, storage.ErrPersonNotFound):
+		log.Info("person not found")
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("person not found"))
+	default:
+		log.Error("failed to get person", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+	}
+}
+
+// writeUpdateError answers a failed conditional write and reports whether
+// the caller may go on with a successful response.
+func writeUpdateError(w http.ResponseWriter, r *http.Request, log *slog.Logger, current *entity.Person, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, storage.ErrVersionConflict):
+		log.Info("version conflict", slog.Int64("current_version", current.Version))
+
+		w.Header().Set("ETag", etag.Format(current.Version))
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, Response{
+			Response: resp.Error("version conflict"),
+			Person:   current,
+		})
+	case errors.Is(err, storage.ErrPersonNotFound):
+		log.Info("person not found")
+
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("person not found"))
+	default:
+		log.Error("failed to update person", sl.Err(err))
+
+		render.JSON(w, r, resp.Error("internal error"))
+	}
+
+	return false
+}
+
+func responseOK(w http.ResponseWriter, r *http.Request, person *entity.Person, job *entity.Job) {
+	w.Header().Set("ETag", etag.Format(person.Version))
+
+	response := Response{
+		Response: resp.OK(),
+		Person:   person,
+	}
+	if job != nil {
+		response.JobID = job.ID
+	}
+
+	render.JSON(w, r, response)
+}
+
+// validateFields holds a replacement to the same field rules as a patch.
+func validateFields(req Request) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
 	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"name", "surname", "patronymic", "age", "gender", "country"} {
+		if _, err := fieldValidators[field](field, doc[field]); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
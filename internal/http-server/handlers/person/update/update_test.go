@@ -0,0 +1,35 @@
+package update
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFields(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	age := func(i int64) *int64 { return &i }
+
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{name: "valid", req: Request{Name: "Ivan", Surname: "Ivanov", Age: age(30), Gender: ptr("male"), Country: ptr("RU")}},
+		{name: "unset optionals", req: Request{Name: "Ivan", Surname: "Ivanov"}},
+		{name: "blank name", req: Request{Name: " ", Surname: "Ivanov"}, wantErr: true},
+		{name: "long surname", req: Request{Name: "Ivan", Surname: strings.Repeat("a", 101)}, wantErr: true},
+		{name: "long patronymic", req: Request{Name: "Ivan", Surname: "Ivanov", Patronymic: strings.Repeat("a", 101)}, wantErr: true},
+		{name: "age out of range", req: Request{Name: "Ivan", Surname: "Ivanov", Age: age(-1)}, wantErr: true},
+		{name: "unknown gender", req: Request{Name: "Ivan", Surname: "Ivanov", Gender: ptr("other")}, wantErr: true},
+		{name: "long country", req: Request{Name: "Ivan", Surname: "Ivanov", Country: ptr("RUSSIA")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFields(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
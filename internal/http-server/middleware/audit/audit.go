@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"person-extender/internal/lib/audit"
+)
+
+const (
+	HeaderActor    = "X-Actor"
+	ActorAnonymous = "anonymous"
+
+	// MaxActorLength matches the width of person_history.actor.
+	MaxActorLength = 255
+)
+
+// New stores the actor, taken from the X-Actor header, and the request ID in
+// the request context for the audit trail. It must run after
+// middleware.RequestID.
+//
+// The header is set by the client and is not authenticated, so the recorded
+// actor is advisory only. Longer values are cut to MaxActorLength characters.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			actor := r.Header.Get(HeaderActor)
+			if actor == "" {
+				actor = ActorAnonymous
+			}
+			if runes := []rune(actor); len(runes) > MaxActorLength {
+				actor = string(runes[:MaxActorLength])
+			}
+
+			ctx := audit.WithMeta(r.Context(), audit.Meta{
+				Actor:     actor,
+				RequestID: middleware.GetReqID(r.Context()),
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
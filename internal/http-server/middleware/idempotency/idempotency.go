@@ -0,0 +1,199 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"person-extender/internal/entity"
+	resp "person-extender/internal/lib/api/response"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLen   = 255
+	maxBodySize = 1 << 20
+)
+
+// storedHeaders are kept with a response and set again on replay.
+var storedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+type Store interface {
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (*entity.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, key string, response *entity.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// New makes requests carrying an Idempotency-Key header safe to retry for
+// ttl. The first successful response for a key is stored and replayed to
+// retries with the same method, path and body; reusing the key for another
+// request is rejected with 422, and a retry arriving while the first request
+// still runs gets 409. A request that has not finished within lease, e.g.
+// because its instance died, may be taken over by a retry. Failed responses
+// are not stored, so that a retry runs the request again. Requests without
+// the header pass through.
+func New(log *slog.Logger, store Store, ttl, lease time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/idempotency"),
+		)
+
+		log.Info("idempotency middleware enabled", slog.Duration("ttl", ttl), slog.Duration("lease", lease))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("idempotency_key", key),
+			)
+
+			if len(key) > maxKeyLen {
+				log.Error("idempotency key is too long")
+
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid Idempotency-Key header"))
+
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				log.Error("failed to read request body", sl.Err(err))
+
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, resp.Error("failed to read request"))
+
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := requestHash(r, body)
+
+			stored, err := store.ReserveIdempotencyKey(r.Context(), key, hash, ttl, lease)
+			switch {
+			case err != nil && !errors.Is(err, storage.ErrKeyInUse):
+				log.Error("failed to reserve idempotency key", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("internal error"))
+
+				return
+			case stored != nil && stored.RequestHash != hash:
+				log.Info("idempotency key reused for another request")
+
+				render.Status(r, http.StatusUnprocessableEntity)
+				render.JSON(w, r, resp.Error("Idempotency-Key was used for another request"))
+
+				return
+			case err != nil:
+				log.Info("idempotency key in use")
+
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("a request with this Idempotency-Key is in progress"))
+
+				return
+			case stored != nil:
+				log.Info("stored response replayed")
+
+				replay(w, stored)
+
+				return
+			}
+
+			var buf bytes.Buffer
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			saved := false
+			defer func() {
+				if saved {
+					return
+				}
+
+				// The client may be gone, the reservation must go anyway.
+				if err := store.ReleaseIdempotencyKey(context.WithoutCancel(r.Context()), key); err != nil {
+					log.Error("failed to release idempotency key", sl.Err(err))
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			if !succeeded(ww.Status(), buf.Bytes()) {
+				return
+			}
+
+			response := &entity.IdempotentResponse{
+				RequestHash: hash,
+				StatusCode:  ww.Status(),
+				Header:      make(map[string]string),
+				Body:        buf.Bytes(),
+			}
+			for _, name := range storedHeaders {
+				if v := ww.Header().Get(name); v != "" {
+					response.Header[name] = v
+				}
+			}
+
+			if err := store.SaveIdempotentResponse(context.WithoutCancel(r.Context()), key, response); err != nil {
+				log.Error("failed to store response", sl.Err(err))
+
+				return
+			}
+			saved = true
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// succeeded tells apart successful responses; handlers report most errors in
+// the body with a 200 status.
+func succeeded(status int, body []byte) bool {
+	if status < 200 || status >= 300 {
+		return false
+	}
+
+	var r resp.Response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return false
+	}
+
+	return r.Status == resp.StatusOK
+}
+
+func replay(w http.ResponseWriter, stored *entity.IdempotentResponse) {
+	for name, v := range stored.Header {
+		w.Header().Set(name, v)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
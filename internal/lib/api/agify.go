@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type agifyResponse struct {
+	Age   *int64 `json:"age"`
+	Count *int64 `json:"count"`
+}
+
+type agify struct {
+	httpProvider
+}
+
+func newAgify(client *http.Client, cfg ProviderConfig) (Provider, error) {
+	p, err := newHTTPProvider(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &agify{httpProvider: p}, nil
+}
+
+func (p *agify) Enrich(ctx context.Context, name string) (Partial, error) {
+	var res agifyResponse
+	if err := p.get(ctx, name, &res); err != nil {
+		return Partial{}, err
+	}
+
+	return Partial{Age: res.Age, AgeCount: res.Count}, nil
+}
@@ -1,96 +1,200 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/translit"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProviderAgify       = "agify"
+	ProviderGenderize   = "genderize"
+	ProviderNationalize = "nationalize"
 )
 
 type PersonExtends struct {
-	Age     int64  `json:"age"`
-	Gender  string `json:"gender"`
-	Country string `json:"country"`
+	Partial
+	// Providers records whether each provider succeeded, see entity.ProviderOK.
+	Providers map[string]string `json:"providers"`
 }
 
-type AgeResponse struct {
-	Age int64 `json:"age"`
+// Apply copies the enrichment result onto person. Fields that were not
+// resolved are left nil.
+func (p *PersonExtends) Apply(person *entity.Person) {
+	person.Age = p.Age
+	person.AgeCount = p.AgeCount
+	person.Gender = p.Gender
+	person.GenderProbability = p.GenderProbability
+	person.GenderCount = p.GenderCount
+	person.Country = p.Country
+	person.Countries = p.Countries
+	person.EnrichmentStatus = p.State()
+	person.Enrichment = p.Providers
 }
 
-type GenderResponse struct {
-	Gender string `json:"gender"`
+// State summarizes Providers as one of the entity.Enrichment* statuses.
+func (p *PersonExtends) State() string {
+	return entity.EnrichmentState(p.Providers)
 }
 
-type CountryResponse struct {
-	Country []Country `json:"country"`
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
 }
 
-type Country struct {
-	CountryId   string  `json:"country_id"`
-	Probability float64 `json:"probability"`
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
 }
 
-func GetPersonExtends(name string) (*PersonExtends, error) {
-	ageURL := fmt.Sprintf("%s?name=%s", os.Getenv("API_AGIFY_URL"), name)
+// EnrichError collects the failures of the individual providers.
+type EnrichError struct {
+	Errors map[string]error
+}
 
-	res, err := http.Get(ageURL)
-	if err != nil {
-		return nil, err
+func (e *EnrichError) Error() string {
+	providers := make([]string, 0, len(e.Errors))
+	for provider := range e.Errors {
+		providers = append(providers, provider)
 	}
-	defer res.Body.Close()
+	sort.Strings(providers)
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+	msgs := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", provider, e.Errors[provider]))
 	}
 
-	var ageResponse AgeResponse
-	if err := json.Unmarshal(body, &ageResponse); err != nil {
-		return nil, err
-	}
+	return "enrichment failed: " + strings.Join(msgs, "; ")
+}
 
-	genderURL := fmt.Sprintf("%s?name=%s", os.Getenv("API_GENDERIZE_URL"), name)
+type Enricher struct {
+	providers    []Provider
+	allowPartial bool
+}
 
-	res, err = http.Get(genderURL)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
+type EnricherOption func(*Enricher)
 
-	body, err = io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+// WithPartialResults lets every provider run to completion instead of
+// cancelling the others on the first failure, so that a partial result can
+// be kept. Failures are still reported through the *EnrichError.
+func WithPartialResults() EnricherOption {
+	return func(e *Enricher) {
+		e.allowPartial = true
 	}
+}
+
+func NewEnricher(providers []Provider, opts ...EnricherOption) *Enricher {
+	e := &Enricher{providers: providers}
 
-	var genderResponse GenderResponse
-	if err := json.Unmarshal(body, &genderResponse); err != nil {
-		return nil, err
+	for _, opt := range opts {
+		opt(e)
 	}
 
-	countryURL := fmt.Sprintf("%s?name=%s", os.Getenv("API_NATIONALIZE_URL"), name)
+	return e
+}
+
+func (e *Enricher) AllowsPartial() bool {
+	return e.allowPartial
+}
+
+// Enrich queries all providers concurrently with the Latin form of name.
+// Unless partial results are allowed, the first failure cancels the
+// remaining lookups; only that failure is reported and the cut short lookups
+// are marked entity.ProviderCancelled. The returned result holds whatever
+// was resolved and the error is an *EnrichError keyed by provider name.
+func (e *Enricher) Enrich(ctx context.Context, name string) (*PersonExtends, error) {
+	name = translit.ToLatin(name)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	res, err = http.Get(countryURL)
-	if err != nil {
-		return nil, err
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		errs      = make(map[string]error)
+		cancelled = make(map[string]bool)
+		partials  = make([]Partial, len(e.providers))
+	)
+
+	for i, provider := range e.providers {
+		wg.Add(1)
+
+		go func(i int, provider Provider) {
+			defer wg.Done()
+
+			partial, err := provider.Enrich(ctx, name)
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+
+				// Once the first failure cancelled the lookups, the errors
+				// of the others are caused by that cancellation.
+				if !e.allowPartial && len(errs) > 0 {
+					cancelled[provider.Name()] = true
+
+					return
+				}
+
+				errs[provider.Name()] = err
+
+				if !e.allowPartial {
+					cancel()
+				}
+
+				return
+			}
+
+			partials[i] = partial
+		}(i, provider)
 	}
-	defer res.Body.Close()
 
-	body, err = io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+	wg.Wait()
+
+	result := merge(partials)
+
+	result.Providers = make(map[string]string, len(e.providers))
+	for _, provider := range e.providers {
+		switch _, failed := errs[provider.Name()]; {
+		case failed:
+			result.Providers[provider.Name()] = entity.ProviderFailed
+		case cancelled[provider.Name()]:
+			result.Providers[provider.Name()] = entity.ProviderCancelled
+		default:
+			result.Providers[provider.Name()] = entity.ProviderOK
+		}
 	}
 
-	var countryResponse CountryResponse
-	if err := json.Unmarshal(body, &countryResponse); err != nil {
-		return nil, err
+	if len(errs) > 0 {
+		return result, &EnrichError{Errors: errs}
 	}
 
-	personExtends := &PersonExtends{
-		Age:     ageResponse.Age,
-		Gender:  genderResponse.Gender,
-		Country: countryResponse.Country[0].CountryId,
+	return result, nil
+}
+
+// merge folds partials in provider order, so a later provider overrides the
+// fields resolved by an earlier one.
+func merge(partials []Partial) *PersonExtends {
+	result := &PersonExtends{}
+
+	for _, p := range partials {
+		if p.Age != nil {
+			result.Age = p.Age
+			result.AgeCount = p.AgeCount
+		}
+		if p.Gender != nil {
+			result.Gender = p.Gender
+			result.GenderProbability = p.GenderProbability
+			result.GenderCount = p.GenderCount
+		}
+		if p.Country != nil {
+			result.Country = p.Country
+			result.Countries = p.Countries
+		}
 	}
 
-	return personExtends, nil
+	return result
 }
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"person-extender/internal/lib/logger/sl"
+	"strings"
+	"time"
+)
+
+// Cache stores provider results. GetCachedEnrichment reports a missing or
+// expired entry with found == false and a nil error.
+type Cache interface {
+	GetCachedEnrichment(ctx context.Context, name, provider string, ttl time.Duration) (payload []byte, found bool, err error)
+	SaveCachedEnrichment(ctx context.Context, name, provider string, payload []byte) error
+}
+
+type cachedProvider struct {
+	Provider
+	log   *slog.Logger
+	cache Cache
+	ttl   time.Duration
+}
+
+// Cached serves lookups from cache while they are younger than ttl and stores
+// fresh provider results under the normalized name.
+func Cached(log *slog.Logger, provider Provider, cache Cache, ttl time.Duration) Provider {
+	return &cachedProvider{
+		Provider: provider,
+		log:      log.With(slog.String("provider", provider.Name())),
+		cache:    cache,
+		ttl:      ttl,
+	}
+}
+
+func (p *cachedProvider) Enrich(ctx context.Context, name string) (Partial, error) {
+	const op = "api.cachedProvider.Enrich"
+
+	log := p.log.With(slog.String("op", op))
+
+	key := NormalizeName(name)
+
+	payload, found, err := p.cache.GetCachedEnrichment(ctx, key, p.Name(), p.ttl)
+	switch {
+	case err != nil:
+		log.Warn("failed to read enrichment cache", sl.Err(err))
+	case found:
+		var partial Partial
+		err := json.Unmarshal(payload, &partial)
+		if err == nil {
+			return partial, nil
+		}
+		log.Warn("failed to decode cached enrichment", sl.Err(err))
+	}
+
+	partial, err := p.Provider.Enrich(ctx, name)
+	if err != nil {
+		return Partial{}, err
+	}
+
+	payload, err = json.Marshal(partial)
+	if err != nil {
+		return partial, nil
+	}
+
+	if err := p.cache.SaveCachedEnrichment(ctx, key, p.Name(), payload); err != nil {
+		log.Warn("failed to write enrichment cache", sl.Err(err))
+	}
+
+	return partial, nil
+}
+
+// NormalizeName folds case and whitespace so that "  dmitriy " and "Dmitriy"
+// share a cache entry.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,70 @@
+package etag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format renders a row version as a strong entity tag.
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Parse extracts the row version from a strong entity tag produced by
+// Format. Weak tags are rejected, If-Match only compares strongly.
+func Parse(tag string) (int64, bool) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ParseIfMatch extracts the row versions listed in an If-Match header, e.g.
+// `"3", "4"`. "*" is left to the caller.
+func ParseIfMatch(header string) ([]int64, bool) {
+	var versions []int64
+
+	for _, tag := range strings.Split(header, ",") {
+		version, ok := Parse(tag)
+		if !ok {
+			return nil, false
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, true
+}
+
+// Expected returns the version a write conditioned on an If-Match list
+// should expect: current if it is listed, so that the write goes ahead, and
+// otherwise the first listed one, so that it conflicts.
+func Expected(versions []int64, current int64) int64 {
+	for _, version := range versions {
+		if version == current {
+			return current
+		}
+	}
+
+	return versions[0]
+}
+
+// Match reports whether header, an If-None-Match or If-Match list, matches
+// tag. Tags are compared weakly, as If-None-Match requires.
+func Match(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package etag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []int64
+		ok     bool
+	}{
+		{header: `"3"`, want: []int64{3}, ok: true},
+		{header: ` "3" `, want: []int64{3}, ok: true},
+		{header: `"3", "4"`, want: []int64{3, 4}, ok: true},
+		{header: `"3","4","5"`, want: []int64{3, 4, 5}, ok: true},
+		{header: `W/"3"`, ok: false},
+		{header: `"3", W/"4"`, ok: false},
+		{header: `3`, ok: false},
+		{header: `"x"`, ok: false},
+		{header: `"3",`, ok: false},
+		{header: `*`, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := ParseIfMatch(tt.header)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseIfMatch(%q) = %v, %t; want %v, %t", tt.header, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		versions []int64
+		current  int64
+		want     int64
+	}{
+		{versions: []int64{3, 4}, current: 4, want: 4},
+		{versions: []int64{3, 4}, current: 3, want: 3},
+		{versions: []int64{3, 4}, current: 5, want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := Expected(tt.versions, tt.current); got != tt.want {
+			t.Errorf("Expected(%v, %d) = %d, want %d", tt.versions, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: `"3"`, want: true},
+		{header: `W/"3"`, want: true},
+		{header: `"2", "3"`, want: true},
+		{header: `*`, want: true},
+		{header: `"4"`, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.header, Format(3)); got != tt.want {
+			t.Errorf("Match(%q) = %t, want %t", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package filters
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/query"
+	"strconv"
+)
+
+// Schema lists the filterable person fields, see query.Parse for the
+// parameter syntax, e.g. ?age_gte=30&country=RU,UA&surname_iprefix=iv or
+// ?or.a.gender=female&or.b.age_lte=18.
+var Schema = query.Schema{
+	"name":               {Column: "name", Type: query.String},
+	"surname":            {Column: "surname", Type: query.String},
+	"patronymic":         {Column: "patronymic", Type: query.String, Nullable: true},
+	"age":                {Column: "age", Type: query.Int, Nullable: true},
+	"age_count":          {Column: "age_count", Type: query.Int, Nullable: true},
+	"gender":             {Column: "gender", Type: query.String, Nullable: true},
+	"gender_probability": {Column: "gender_probability", Type: query.Float, Nullable: true},
+	"gender_count":       {Column: "gender_count", Type: query.Int, Nullable: true},
+	"country":            {Column: "country", Type: query.String, Nullable: true},
+	"enrichment_status":  {Column: "enrichment_status", Type: query.String},
+}
+
+// SortColumns lists the fields the sort parameter accepts. id breaks ties.
+// Patronymic is stored as NULL or empty, which must not differ for cursors.
+var SortColumns = map[string]query.Field{
+	"id":                 {Column: "id", Type: query.Int},
+	"name":               {Column: "name", Type: query.String},
+	"surname":            {Column: "surname", Type: query.String},
+	"patronymic":         {Column: "COALESCE(patronymic, '')", Type: query.String},
+	"age":                {Column: "age", Type: query.Int},
+	"gender":             {Column: "gender", Type: query.String},
+	"gender_probability": {Column: "gender_probability", Type: query.Float},
+	"country":            {Column: "country", Type: query.String},
+	"enriched_at":        {Column: "enriched_at", Type: query.Time},
+}
+
+// FromQuery reads entity.Filters from the query parameters described by
+// Schema, sort (see query.ParseSort and SortColumns) and include_deleted.
+// params names the other parameters the caller reads from q; anything else
+// is rejected.
+func FromQuery(q url.Values, params ...string) (*entity.Filters, error) {
+	f, err := query.Parse(Schema, q, append(params, "sort", "include_deleted")...)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := query.ParseSort(SortColumns, q.Get("sort"), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	filters := &entity.Filters{
+		Conditions: conditions(f.All),
+		Sort:       make([]entity.SortKey, 0, len(s)),
+	}
+	for _, group := range f.Groups {
+		filters.Groups = append(filters.Groups, conditions(group))
+	}
+	for _, key := range s {
+		filters.Sort = append(filters.Sort, entity.SortKey{Field: key.Name, Desc: key.Desc})
+	}
+
+	if q.Has("include_deleted") {
+		v, err := strconv.ParseBool(q.Get("include_deleted"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid include_deleted value")
+		}
+		filters.IncludeDeleted = v
+	}
+
+	return filters, nil
+}
+
+func conditions(predicates []query.Predicate) []entity.Condition {
+	conditions := make([]entity.Condition, 0, len(predicates))
+	for _, p := range predicates {
+		conditions = append(conditions, entity.Condition{Field: p.Field.Name, Op: string(p.Op), Values: p.Values})
+	}
+
+	return conditions
+}
+
+// ToQuery resolves the fields of f against Schema and SortColumns, for
+// query.Builder to compile. The sort is empty if f has none.
+func ToQuery(f *entity.Filters) (*query.Filter, query.Sort, error) {
+	if f == nil {
+		return nil, nil, nil
+	}
+
+	all, err := predicates(f.Conditions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filter := &query.Filter{All: all}
+	for _, group := range f.Groups {
+		predicates, err := predicates(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter.Groups = append(filter.Groups, predicates)
+	}
+
+	s := make(query.Sort, 0, len(f.Sort))
+	for _, key := range f.Sort {
+		field, ok := SortColumns[key.Field]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: cannot sort by %q", query.ErrInvalid, key.Field)
+		}
+		s = append(s, query.SortKey{Name: key.Field, Column: field.Column, Type: field.Type, Desc: key.Desc})
+	}
+
+	return filter, s, nil
+}
+
+func predicates(conditions []entity.Condition) ([]query.Predicate, error) {
+	predicates := make([]query.Predicate, 0, len(conditions))
+	for _, c := range conditions {
+		field, ok := Schema[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %s", query.ErrInvalid, c.Field)
+		}
+		field.Name = c.Field
+
+		predicates = append(predicates, query.Predicate{Field: field, Op: query.Op(c.Op), Values: c.Values})
+	}
+
+	return predicates, nil
+}
+
+// SortParam formats the sort of f the way the sort parameter reads it.
+func SortParam(f *entity.Filters) string {
+	s := make(query.Sort, 0, len(f.Sort))
+	for _, key := range f.Sort {
+		s = append(s, query.SortKey{Name: key.Field, Desc: key.Desc})
+	}
+
+	return s.String()
+}
+
+// Fingerprint identifies the filters of f, ignoring the sort, so that a
+// cursor is not used with filters other than those it was made for.
+func Fingerprint(f *entity.Filters) string {
+	b, _ := json.Marshal(struct {
+		Conditions     []entity.Condition
+		Groups         [][]entity.Condition
+		IncludeDeleted bool
+	}{f.Conditions, f.Groups, f.IncludeDeleted})
+
+	sum := sha256.Sum256(b)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// SortValues returns the values of p for the keys of s, to build a cursor
+// from.
+func SortValues(p *entity.Person, s []entity.SortKey) []interface{} {
+	values := make([]interface{}, 0, len(s))
+
+	for _, key := range s {
+		var v interface{}
+
+		switch key.Field {
+		case "id":
+			v = p.ID
+		case "name":
+			v = p.Name
+		case "surname":
+			v = p.Surname
+		case "patronymic":
+			v = p.Patronymic
+		case "age":
+			v = nullable(p.Age)
+		case "gender":
+			v = nullable(p.Gender)
+		case "gender_probability":
+			v = nullable(p.GenderProbability)
+		case "country":
+			v = nullable(p.Country)
+		case "enriched_at":
+			v = nullable(p.EnrichedAt)
+		}
+
+		values = append(values, v)
+	}
+
+	return values
+}
+
+// nullable turns a nil pointer into an untyped nil and dereferences others.
+func nullable[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+
+	return *p
+}
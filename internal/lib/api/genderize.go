@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+type genderizeResponse struct {
+	Gender      *string  `json:"gender"`
+	Probability *float64 `json:"probability"`
+	Count       *int64   `json:"count"`
+}
+
+type genderize struct {
+	httpProvider
+}
+
+func newGenderize(client *http.Client, cfg ProviderConfig) (Provider, error) {
+	p, err := newHTTPProvider(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &genderize{httpProvider: p}, nil
+}
+
+func (p *genderize) Enrich(ctx context.Context, name string) (Partial, error) {
+	var res genderizeResponse
+	if err := p.get(ctx, name, &res); err != nil {
+		return Partial{}, err
+	}
+
+	return Partial{Gender: res.Gender, GenderProbability: res.Probability, GenderCount: res.Count}, nil
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+const KindGeneric = "generic"
+
+// generic talks to in-house sources that already answer with a Partial,
+// e.g. {"age": 42, "gender": "male", "country": "RU"}.
+type generic struct {
+	httpProvider
+}
+
+func newGeneric(client *http.Client, cfg ProviderConfig) (Provider, error) {
+	p, err := newHTTPProvider(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &generic{httpProvider: p}, nil
+}
+
+func (p *generic) Enrich(ctx context.Context, name string) (Partial, error) {
+	var res Partial
+	if err := p.get(ctx, name, &res); err != nil {
+		return Partial{}, err
+	}
+
+	return res, nil
+}
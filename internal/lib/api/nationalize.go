@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"person-extender/internal/entity"
+)
+
+type nationalizeResponse struct {
+	Country []nationalizeCountry `json:"country"`
+}
+
+type nationalizeCountry struct {
+	CountryId   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+type nationalize struct {
+	httpProvider
+}
+
+func newNationalize(client *http.Client, cfg ProviderConfig) (Provider, error) {
+	p, err := newHTTPProvider(client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nationalize{httpProvider: p}, nil
+}
+
+func (p *nationalize) Enrich(ctx context.Context, name string) (Partial, error) {
+	var res nationalizeResponse
+	if err := p.get(ctx, name, &res); err != nil {
+		return Partial{}, err
+	}
+	if len(res.Country) == 0 {
+		// An unknown name is an answer too: the country stays unset.
+		return Partial{}, nil
+	}
+
+	// nationalize ranks candidates by probability, most likely first.
+	countries := make([]entity.CountryCandidate, 0, len(res.Country))
+	for _, c := range res.Country {
+		countries = append(countries, entity.CountryCandidate{
+			CountryID:   c.CountryId,
+			Probability: c.Probability,
+		})
+	}
+
+	return Partial{Country: &res.Country[0].CountryId, Countries: countries}, nil
+}
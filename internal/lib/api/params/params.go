@@ -0,0 +1,31 @@
+package params
+
+import (
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	resp "person-extender/internal/lib/api/response"
+	"strconv"
+)
+
+// Int reads the optional integer query parameter name, def if it is
+// missing; max < 0 means unbounded. On an invalid value it answers the
+// request with 400 and reports false.
+func Int(w http.ResponseWriter, r *http.Request, log *slog.Logger, name string, def, min, max int64) (int64, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i < min || (max >= 0 && i > max) {
+		log.Error("invalid "+name+" value", slog.String(name, v))
+
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("invalid "+name+" value"))
+
+		return 0, false
+	}
+
+	return i, true
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"person-extender/internal/entity"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Partial is the part of a person's demographics resolved by a single
+// provider. Fields a provider does not know about are left nil.
+type Partial struct {
+	Age               *int64                    `json:"age,omitempty"`
+	AgeCount          *int64                    `json:"age_count,omitempty"`
+	Gender            *string                   `json:"gender,omitempty"`
+	GenderProbability *float64                  `json:"gender_probability,omitempty"`
+	GenderCount       *int64                    `json:"gender_count,omitempty"`
+	Country           *string                   `json:"country,omitempty"`
+	Countries         []entity.CountryCandidate `json:"countries,omitempty"`
+}
+
+type Provider interface {
+	Name() string
+	Enrich(ctx context.Context, name string) (Partial, error)
+}
+
+type ProviderConfig struct {
+	Name string
+	Kind string
+	URL  string
+	// Options are sent as additional query parameters with every lookup,
+	// e.g. an API key or country_id.
+	Options map[string]string
+}
+
+type Factory func(client *http.Client, cfg ProviderConfig) (Provider, error)
+
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// DefaultRegistry returns a registry with the built-in provider kinds.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+
+	r.Register(ProviderAgify, newAgify)
+	r.Register(ProviderGenderize, newGenderize)
+	r.Register(ProviderNationalize, newNationalize)
+	r.Register(KindGeneric, newGeneric)
+
+	return r
+}
+
+func (r *Registry) Register(kind string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[kind] = factory
+}
+
+func (r *Registry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kinds := make([]string, 0, len(r.factories))
+	for kind := range r.factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+func (r *Registry) Build(client *http.Client, cfgs []ProviderConfig) ([]Provider, error) {
+	const op = "api.Registry.Build"
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers := make([]Provider, 0, len(cfgs))
+	seen := make(map[string]bool, len(cfgs))
+
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			cfg.Name = cfg.Kind
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("%s: duplicate provider %q", op, cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		factory, ok := r.factories[cfg.Kind]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown provider kind %q", op, cfg.Kind)
+		}
+
+		provider, err := factory(client, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, cfg.Name, err)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// httpProvider holds what every HTTP-backed provider needs to issue a lookup.
+type httpProvider struct {
+	name   string
+	url    string
+	params url.Values
+	client *http.Client
+}
+
+func newHTTPProvider(client *http.Client, cfg ProviderConfig) (httpProvider, error) {
+	if cfg.URL == "" {
+		return httpProvider{}, fmt.Errorf("url is not set")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	params := make(url.Values, len(cfg.Options))
+	for key, value := range cfg.Options {
+		if key == "name" {
+			return httpProvider{}, fmt.Errorf("option %q is reserved", key)
+		}
+		params.Set(key, value)
+	}
+
+	return httpProvider{name: cfg.Name, url: cfg.URL, params: params, client: client}, nil
+}
+
+func (p httpProvider) Name() string {
+	return p.name
+}
+
+func (p httpProvider) get(ctx context.Context, name string, v interface{}) error {
+	params := url.Values{"name": {name}}
+	for key, values := range p.params {
+		params[key] = values
+	}
+
+	reqURL := p.url + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{
+			StatusCode: res.StatusCode,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay-seconds and
+// an HTTP-date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
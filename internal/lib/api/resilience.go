@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"person-extender/internal/lib/breaker"
+	"person-extender/internal/lib/ratelimit"
+	"time"
+
+	"github.com/sethvargo/go-retry"
+)
+
+type RetryPolicy struct {
+	MaxAttempts   uint64
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	JitterPercent uint64
+}
+
+type retryProvider struct {
+	Provider
+	policy RetryPolicy
+}
+
+// WithRetry retries transient failures with exponential backoff. A Retry-After
+// sent by the provider takes precedence when it asks for a longer pause; one
+// longer than MaxDelay ends the retries instead.
+func WithRetry(provider Provider, policy RetryPolicy) Provider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 100 * time.Millisecond
+	}
+
+	return &retryProvider{Provider: provider, policy: policy}
+}
+
+func (p *retryProvider) Enrich(ctx context.Context, name string) (Partial, error) {
+	var (
+		partial    Partial
+		retryAfter time.Duration
+	)
+
+	backoff := retry.NewExponential(p.policy.BaseDelay)
+	if p.policy.JitterPercent > 0 {
+		backoff = retry.WithJitterPercent(p.policy.JitterPercent, backoff)
+	}
+	if p.policy.MaxDelay > 0 {
+		backoff = retry.WithCappedDuration(p.policy.MaxDelay, backoff)
+	}
+	backoff = retry.WithMaxRetries(p.policy.MaxAttempts-1, backoff)
+	backoff = honorRetryAfter(backoff, &retryAfter, p.policy.MaxDelay)
+
+	err := retry.Do(ctx, backoff, func(ctx context.Context) error {
+		retryAfter = 0
+
+		res, err := p.Provider.Enrich(ctx, name)
+		if err != nil {
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) {
+				retryAfter = statusErr.RetryAfter
+			}
+
+			if isTransient(ctx, err) {
+				return retry.RetryableError(err)
+			}
+
+			return err
+		}
+
+		partial = res
+
+		return nil
+	})
+
+	return partial, err
+}
+
+func honorRetryAfter(next retry.Backoff, retryAfter *time.Duration, maxDelay time.Duration) retry.Backoff {
+	return retry.BackoffFunc(func() (time.Duration, bool) {
+		val, stop := next.Next()
+		if stop {
+			return 0, true
+		}
+
+		if maxDelay > 0 && *retryAfter > maxDelay {
+			return 0, true
+		}
+		if *retryAfter > val {
+			val = *retryAfter
+		}
+
+		return val, false
+	})
+}
+
+type breakerProvider struct {
+	Provider
+	breaker *breaker.Breaker
+}
+
+// WithBreaker stops calling the provider while its breaker is open. Only
+// transient failures count against the breaker.
+func WithBreaker(provider Provider, b *breaker.Breaker) Provider {
+	return &breakerProvider{Provider: provider, breaker: b}
+}
+
+func (p *breakerProvider) Enrich(ctx context.Context, name string) (Partial, error) {
+	if err := p.breaker.Allow(); err != nil {
+		return Partial{}, err
+	}
+
+	partial, err := p.Provider.Enrich(ctx, name)
+
+	switch {
+	case err == nil:
+		p.breaker.Success()
+	case ctx.Err() != nil:
+		p.breaker.Release()
+	case isTransient(ctx, err):
+		p.breaker.Failure()
+	default:
+		// The provider answered, it just had nothing useful for this name.
+		p.breaker.Success()
+	}
+
+	return partial, err
+}
+
+// isTransient reports whether err is worth retrying: network failures,
+// rate limiting and server-side errors. Cancellation by the caller is not.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, breaker.ErrOpen) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+type rateLimitedProvider struct {
+	Provider
+	limiter *ratelimit.Limiter
+}
+
+// WithRateLimit makes each lookup wait for a token from limiter first.
+func WithRateLimit(provider Provider, limiter *ratelimit.Limiter) Provider {
+	return &rateLimitedProvider{Provider: provider, limiter: limiter}
+}
+
+func (p *rateLimitedProvider) Enrich(ctx context.Context, name string) (Partial, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return Partial{}, err
+	}
+
+	return p.Provider.Enrich(ctx, name)
+}
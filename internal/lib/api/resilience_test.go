@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type flakyProvider struct {
+	calls      int
+	retryAfter time.Duration
+}
+
+func (p *flakyProvider) Name() string { return "flaky" }
+
+func (p *flakyProvider) Enrich(ctx context.Context, name string) (Partial, error) {
+	p.calls++
+
+	return Partial{}, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: p.retryAfter}
+}
+
+func TestWithRetryRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		wantCalls  int
+	}{
+		{name: "within max delay", retryAfter: 5 * time.Millisecond, wantCalls: 3},
+		{name: "beyond max delay", retryAfter: time.Hour, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &flakyProvider{retryAfter: tt.retryAfter}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if _, err := WithRetry(p, policy).Enrich(ctx, "john"); err == nil {
+				t.Fatal("Enrich() error = nil, want the status error")
+			}
+			if ctx.Err() != nil {
+				t.Fatal("Enrich() waited out the Retry-After")
+			}
+			if p.calls != tt.wantCalls {
+				t.Fatalf("provider called %d times, want %d", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
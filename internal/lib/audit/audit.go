@@ -0,0 +1,30 @@
+package audit
+
+import "context"
+
+// ActorSystem is recorded for changes made outside of a request, e.g. by
+// the background workers.
+const ActorSystem = "system"
+
+// Meta identifies who made a change and in which request.
+type Meta struct {
+	Actor     string
+	RequestID string
+}
+
+type ctxKey struct{}
+
+func WithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, ctxKey{}, meta)
+}
+
+// FromContext returns the Meta stored in ctx, with the actor defaulting to
+// ActorSystem.
+func FromContext(ctx context.Context) Meta {
+	meta, _ := ctx.Value(ctxKey{}).(Meta)
+	if meta.Actor == "" {
+		meta.Actor = ActorSystem
+	}
+
+	return meta
+}
@@ -0,0 +1,182 @@
+package breaker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Snapshot struct {
+	Name     string     `json:"name"`
+	State    State      `json:"state"`
+	Failures int        `json:"consecutive_failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker opens after threshold consecutive failures and rejects calls until
+// openTimeout has passed. Then a single trial call is let through: success
+// closes the breaker, failure opens it again.
+type Breaker struct {
+	mu          sync.Mutex
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	state       State
+	failures    int
+	openedAt    time.Time
+	trial       bool
+}
+
+func New(name string, threshold int, openTimeout time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &Breaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return ErrOpen
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+
+		return nil
+	case StateHalfOpen:
+		if b.trial {
+			return ErrOpen
+		}
+		b.trial = true
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release gives back a trial slot without judging the provider, e.g. when the
+// call was cancelled by the caller.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Snapshot{
+		Name:     b.name,
+		State:    b.state,
+		Failures: b.failures,
+	}
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.openTimeout {
+		s.State = StateHalfOpen
+	}
+
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+
+	return s
+}
+
+type Group struct {
+	mu       sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+func NewGroup() *Group {
+	return &Group{breakers: make(map[string]*Breaker)}
+}
+
+func (g *Group) Add(b *Breaker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.breakers[b.Name()] = b
+}
+
+func (g *Group) Snapshots() []Snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	snapshots := make([]Snapshot, 0, len(g.breakers))
+	for _, b := range g.breakers {
+		snapshots = append(snapshots, b.Snapshot())
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name < snapshots[j].Name
+	})
+
+	return snapshots
+}
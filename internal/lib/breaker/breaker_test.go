@@ -0,0 +1,147 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const openTimeout = 20 * time.Millisecond
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := New("test", 3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("call %d: Allow() = %v, want nil", i, err)
+		}
+		b.Failure()
+	}
+
+	if got := b.Snapshot().State; got != StateClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", got)
+	}
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() = %v, want nil", err)
+	}
+	b.Failure()
+
+	if got := b.Snapshot().State; got != StateOpen {
+		t.Fatalf("state after 3 failures = %s, want open", got)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Allow() on open breaker = %v, want ErrOpen", err)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := New("test", 2, time.Hour)
+
+	b.Failure()
+	b.Success()
+	b.Failure()
+
+	s := b.Snapshot()
+	if s.State != StateClosed {
+		t.Fatalf("state = %s, want closed", s.State)
+	}
+	if s.Failures != 1 {
+		t.Fatalf("failures = %d, want 1", s.Failures)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome func(b *Breaker)
+		want    State
+		allowed bool
+	}{
+		{
+			name:    "trial success closes",
+			outcome: (*Breaker).Success,
+			want:    StateClosed,
+			allowed: true,
+		},
+		{
+			name:    "trial failure reopens",
+			outcome: (*Breaker).Failure,
+			want:    StateOpen,
+			allowed: false,
+		},
+		{
+			name:    "released trial stays half-open",
+			outcome: (*Breaker).Release,
+			want:    StateHalfOpen,
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("test", 1, openTimeout)
+
+			b.Failure()
+			if err := b.Allow(); !errors.Is(err, ErrOpen) {
+				t.Fatalf("Allow() before timeout = %v, want ErrOpen", err)
+			}
+
+			time.Sleep(openTimeout + 5*time.Millisecond)
+
+			if got := b.Snapshot().State; got != StateHalfOpen {
+				t.Fatalf("state after timeout = %s, want half-open", got)
+			}
+			if err := b.Allow(); err != nil {
+				t.Fatalf("trial Allow() = %v, want nil", err)
+			}
+			if err := b.Allow(); !errors.Is(err, ErrOpen) {
+				t.Fatalf("second Allow() during trial = %v, want ErrOpen", err)
+			}
+
+			tt.outcome(b)
+
+			if got := b.Snapshot().State; got != tt.want {
+				t.Fatalf("state after trial = %s, want %s", got, tt.want)
+			}
+			if err := b.Allow(); (err == nil) != tt.allowed {
+				t.Fatalf("Allow() after trial = %v, want allowed %t", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBreakerSnapshotOpenedAt(t *testing.T) {
+	b := New("test", 1, time.Hour)
+
+	if s := b.Snapshot(); s.OpenedAt != nil {
+		t.Fatalf("closed breaker has OpenedAt %v", s.OpenedAt)
+	}
+
+	before := time.Now()
+	b.Failure()
+
+	s := b.Snapshot()
+	if s.OpenedAt == nil || s.OpenedAt.Before(before) {
+		t.Fatalf("OpenedAt = %v, want at or after %v", s.OpenedAt, before)
+	}
+}
+
+func TestGroupSnapshotsSorted(t *testing.T) {
+	g := NewGroup()
+	g.Add(New("nationalize", 1, time.Hour))
+	g.Add(New("agify", 1, time.Hour))
+	g.Add(New("genderize", 1, time.Hour))
+
+	snapshots := g.Snapshots()
+
+	want := []string{"agify", "genderize", "nationalize"}
+	if len(snapshots) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(snapshots), len(want))
+	}
+	for i, name := range want {
+		if snapshots[i].Name != name {
+			t.Fatalf("snapshot %d = %s, want %s", i, snapshots[i].Name, name)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPointer = errors.New("invalid JSON pointer")
+	ErrPathNotFound   = errors.New("path not found")
+	ErrTestFailed     = errors.New("test operation failed")
+)
+
+// Operation is a single RFC 6902 JSON Patch operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Merge applies an RFC 7396 merge patch to target and returns the result.
+// Both are documents as produced by json.Unmarshal into interface{}.
+func Merge(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	result := make(map[string]interface{}, len(targetObj))
+	for k, v := range targetObj {
+		result[k] = v
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+
+		result[k] = Merge(result[k], v)
+	}
+
+	return result
+}
+
+// Apply runs ops against doc in order and returns the patched document. doc
+// is not modified. The patch is atomic: on error nothing is returned.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	doc = deepCopy(doc)
+
+	for i, op := range ops {
+		var err error
+
+		switch op.Op {
+		case "add":
+			var value interface{}
+			if value, err = decodeValue(op.Value); err == nil {
+				doc, err = add(doc, op.Path, value)
+			}
+		case "remove":
+			doc, _, err = remove(doc, op.Path)
+		case "replace":
+			var value interface{}
+			if value, err = decodeValue(op.Value); err == nil {
+				if doc, _, err = remove(doc, op.Path); err == nil {
+					doc, err = add(doc, op.Path, value)
+				}
+			}
+		case "move":
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("cannot move %q into its own child", op.From)
+				break
+			}
+
+			var value interface{}
+			if doc, value, err = remove(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, value)
+			}
+		case "copy":
+			var value interface{}
+			if value, err = get(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, deepCopy(value))
+			}
+		case "test":
+			var value, actual interface{}
+			if value, err = decodeValue(op.Value); err == nil {
+				if actual, err = get(doc, op.Path); err == nil && !reflect.DeepEqual(actual, value) {
+					err = fmt.Errorf("%w at %q", ErrTestFailed, op.Path)
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown operation %q", op.Op)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("value is required")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// parsePointer splits an RFC 6901 pointer into unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPointer, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: bad array index %q", ErrInvalidPointer, token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: bad array index %q", ErrInvalidPointer, token)
+	}
+
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrPathNotFound, i)
+	}
+
+	return i, nil
+}
+
+func get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	cur := doc
+	for _, t := range tokens {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[t]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+			}
+			cur = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+		}
+	}
+
+	return cur, nil
+}
+
+// add inserts value at pointer and returns the, possibly replaced, root.
+func add(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return update(doc, tokens, pointer, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[last] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(last, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+		}
+	})
+}
+
+// remove deletes the value at pointer and returns the new root and the
+// removed value.
+func remove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed interface{}
+
+	doc, err = update(doc, tokens, pointer, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			v, ok := node[last]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+			}
+			removed = v
+			delete(node, last)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(last, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc, removed, nil
+}
+
+// update walks to the parent of the last token, lets fn change it and writes
+// the result back, since changing an array may reallocate it.
+func update(doc interface{}, tokens []string, pointer string, fn func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+
+	head := tokens[0]
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[head]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+		}
+		updated, err := update(child, tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[head] = updated
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(head, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(node[i], tokens[1:], pointer, fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrPathNotFound, pointer)
+	}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(node))
+		for k, v := range node {
+			c[k] = deepCopy(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(node))
+		for i, v := range node {
+			c[i] = deepCopy(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
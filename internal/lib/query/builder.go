@@ -0,0 +1,132 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Builder accumulates AND-ed SQL conditions with positional parameters.
+// Values never end up in the SQL text, only the columns of schema fields.
+type Builder struct {
+	conditions []string
+	params     []interface{}
+}
+
+// Param adds a parameter and returns its placeholder.
+func (b *Builder) Param(v interface{}) string {
+	b.params = append(b.params, v)
+
+	return fmt.Sprintf("$%d", len(b.params))
+}
+
+// Where adds a condition. Values must go through Param.
+func (b *Builder) Where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// Filter adds the conditions of f.
+func (b *Builder) Filter(f *Filter) error {
+	if f == nil {
+		return nil
+	}
+
+	for _, p := range f.All {
+		condition, err := b.predicate(p)
+		if err != nil {
+			return err
+		}
+		b.Where(condition)
+	}
+
+	if len(f.Groups) == 0 {
+		return nil
+	}
+
+	groups := make([]string, 0, len(f.Groups))
+	for _, group := range f.Groups {
+		conditions := make([]string, 0, len(group))
+		for _, p := range group {
+			condition, err := b.predicate(p)
+			if err != nil {
+				return err
+			}
+			conditions = append(conditions, condition)
+		}
+		groups = append(groups, "("+strings.Join(conditions, " AND ")+")")
+	}
+	b.Where("(" + strings.Join(groups, " OR ") + ")")
+
+	return nil
+}
+
+// SQL returns the WHERE clause, including the leading space, or "".
+func (b *Builder) SQL() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+func (b *Builder) Params() []interface{} {
+	return b.params
+}
+
+func (b *Builder) predicate(p Predicate) (string, error) {
+	field, column := p.Field, p.Field.Column
+	if column == "" {
+		return "", fmt.Errorf("%w: %s has no column", ErrInvalid, field.Name)
+	}
+
+	if p.Op == OpNull {
+		isNull, _ := p.Values[0].(bool)
+
+		target := column
+		if field.Type == String {
+			target = "NULLIF(" + column + ", '')"
+		}
+		if isNull {
+			return target + " IS NULL", nil
+		}
+		return target + " IS NOT NULL", nil
+	}
+
+	if len(p.Values) == 0 {
+		return "", fmt.Errorf("%w: %s has no value", ErrInvalid, field.Name)
+	}
+
+	switch p.Op {
+	case OpEq:
+		return column + " = " + b.Param(p.Values[0]), nil
+	case OpIn:
+		placeholders := make([]string, 0, len(p.Values))
+		for _, v := range p.Values {
+			placeholders = append(placeholders, b.Param(v))
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")", nil
+	case OpGt:
+		return column + " > " + b.Param(p.Values[0]), nil
+	case OpGte:
+		return column + " >= " + b.Param(p.Values[0]), nil
+	case OpLt:
+		return column + " < " + b.Param(p.Values[0]), nil
+	case OpLte:
+		return column + " <= " + b.Param(p.Values[0]), nil
+	case OpPrefix:
+		return column + " LIKE " + b.Param(escapeLike(p.Values[0])+"%"), nil
+	case OpIPrefix:
+		return column + " ILIKE " + b.Param(escapeLike(p.Values[0])+"%"), nil
+	case OpIEq:
+		return "lower(" + column + ") = lower(" + b.Param(p.Values[0]) + ")", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported operator %s", ErrInvalid, p.Op)
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(v interface{}) string {
+	s, _ := v.(string)
+
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,140 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBuilderFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		sql    string
+		params []interface{}
+	}{
+		{
+			name:   "nil",
+			filter: nil,
+			sql:    "",
+		},
+		{
+			name:   "eq",
+			filter: &Filter{All: []Predicate{pred("age", OpEq, int64(30))}},
+			sql:    " WHERE age = $1",
+			params: []interface{}{int64(30)},
+		},
+		{
+			name:   "in",
+			filter: &Filter{All: []Predicate{pred("name", OpIn, "a", "b")}},
+			sql:    " WHERE name IN ($1, $2)",
+			params: []interface{}{"a", "b"},
+		},
+		{
+			name: "ranges",
+			filter: &Filter{All: []Predicate{
+				pred("age", OpGt, int64(1)),
+				pred("age", OpGte, int64(2)),
+				pred("age", OpLt, int64(3)),
+				pred("age", OpLte, int64(4)),
+			}},
+			sql:    " WHERE age > $1 AND age >= $2 AND age < $3 AND age <= $4",
+			params: []interface{}{int64(1), int64(2), int64(3), int64(4)},
+		},
+		{
+			name:   "prefix escapes wildcards",
+			filter: &Filter{All: []Predicate{pred("name", OpPrefix, `50%_a\b`)}},
+			sql:    " WHERE name LIKE $1",
+			params: []interface{}{`50\%\_a\\b%`},
+		},
+		{
+			name:   "iprefix",
+			filter: &Filter{All: []Predicate{pred("name", OpIPrefix, "iv")}},
+			sql:    " WHERE name ILIKE $1",
+			params: []interface{}{"iv%"},
+		},
+		{
+			name:   "ieq",
+			filter: &Filter{All: []Predicate{pred("name", OpIEq, "Ivan")}},
+			sql:    " WHERE lower(name) = lower($1)",
+			params: []interface{}{"Ivan"},
+		},
+		{
+			name:   "null string counts empty",
+			filter: &Filter{All: []Predicate{pred("name", OpNull, true)}},
+			sql:    " WHERE NULLIF(name, '') IS NULL",
+		},
+		{
+			name:   "not null",
+			filter: &Filter{All: []Predicate{pred("age", OpNull, false)}},
+			sql:    " WHERE age IS NOT NULL",
+		},
+		{
+			name: "groups",
+			filter: &Filter{
+				All: []Predicate{pred("age", OpGte, int64(18))},
+				Groups: [][]Predicate{
+					{pred("name", OpEq, "a")},
+					{pred("age", OpLt, int64(10)), pred("score", OpEq, 1.0)},
+				},
+			},
+			sql:    " WHERE age >= $1 AND ((name = $2) OR (age < $3 AND score = $4))",
+			params: []interface{}{int64(18), "a", int64(10), 1.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{}
+			if err := b.Filter(tt.filter); err != nil {
+				t.Fatalf("Filter() error = %v", err)
+			}
+
+			if got := b.SQL(); got != tt.sql {
+				t.Errorf("SQL() = %q, want %q", got, tt.sql)
+			}
+			if got := b.Params(); !reflect.DeepEqual(got, tt.params) {
+				t.Errorf("Params() = %v, want %v", got, tt.params)
+			}
+		})
+	}
+}
+
+func TestBuilderFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Predicate
+	}{
+		{name: "no column", p: Predicate{Field: Field{Name: "x"}, Op: OpEq, Values: []interface{}{1}}},
+		{name: "no value", p: pred("age", OpEq)},
+		{name: "unknown operator", p: pred("age", Op("between"), int64(1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{}
+			if err := b.Filter(&Filter{All: []Predicate{tt.p}}); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Filter() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestBuilderParamsFollowConditions(t *testing.T) {
+	b := &Builder{}
+	b.Where("deleted_at IS NULL")
+	if err := b.Filter(&Filter{All: []Predicate{pred("age", OpEq, int64(30))}}); err != nil {
+		t.Fatal(err)
+	}
+	limit := b.Param(10)
+
+	if got, want := b.SQL(), " WHERE deleted_at IS NULL AND age = $1"; got != want {
+		t.Errorf("SQL() = %q, want %q", got, want)
+	}
+	if limit != "$2" {
+		t.Errorf("Param() = %q, want $2", limit)
+	}
+	if got, want := b.Params(), []interface{}{int64(30), 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Params() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cursor marks a position in a sorted listing: the sort key values of a row,
+// Sort naming the order they belong to and Filter identifying the filters
+// of the listing. Before selects the rows preceding that row instead of the
+// ones following it.
+type Cursor struct {
+	Sort   string        `json:"s"`
+	Filter string        `json:"f,omitempty"`
+	Values []interface{} `json:"v"`
+	Before bool          `json:"b,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor reads a token made by Encode for a listing in s order with
+// the given filter. The cursor must hold no values or one per key of s,
+// each of the key type or nil; they are returned converted to that type.
+func DecodeCursor(token string, s Sort, filter string) (Cursor, error) {
+	var c Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+
+	if c.Sort != s.String() {
+		return c, fmt.Errorf("%w: cursor does not match sort", ErrInvalid)
+	}
+	if c.Filter != filter {
+		return c, fmt.Errorf("%w: cursor does not match filters", ErrInvalid)
+	}
+	if len(c.Values) == 0 {
+		c.Values = nil
+
+		return c, nil
+	}
+	if len(c.Values) != len(s) {
+		return c, fmt.Errorf("%w: cursor does not match sort", ErrInvalid)
+	}
+
+	for i, key := range s {
+		v, err := cursorValue(key.Type, c.Values[i])
+		if err != nil {
+			return c, fmt.Errorf("%w: invalid cursor value for %s", ErrInvalid, key.Name)
+		}
+		c.Values[i] = v
+	}
+
+	return c, nil
+}
+
+func cursorValue(t Type, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case Int, Float:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.New("not a number")
+		}
+		if t == Int {
+			return n.Int64()
+		}
+
+		return n.Float64()
+	case Time:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a timestamp")
+		}
+
+		return time.Parse(time.RFC3339Nano, s)
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a string")
+		}
+
+		return s, nil
+	}
+}
+
+// Seek restricts the rows to those following values in s order or, with
+// before, to those preceding them. Like Sort.SQL it places NULLs last.
+func (b *Builder) Seek(s Sort, values []interface{}, before bool) error {
+	if len(values) != len(s) {
+		return fmt.Errorf("%w: cursor does not match sort", ErrInvalid)
+	}
+
+	var alternatives []string
+
+	for i, key := range s {
+		// Nothing follows a NULL.
+		if values[i] == nil && !before {
+			continue
+		}
+
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, b.equal(s[j], values[j]))
+		}
+		terms = append(terms, b.beyond(key, values[i], before))
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	if len(alternatives) == 0 {
+		b.Where("FALSE")
+
+		return nil
+	}
+
+	b.Where("(" + strings.Join(alternatives, " OR ") + ")")
+
+	return nil
+}
+
+func (b *Builder) equal(key SortKey, v interface{}) string {
+	if v == nil {
+		return key.Column + " IS NULL"
+	}
+
+	return key.Column + " = " + b.Param(v)
+}
+
+// beyond compares a single key. Everything but a NULL precedes a NULL.
+func (b *Builder) beyond(key SortKey, v interface{}, before bool) string {
+	if v == nil {
+		return key.Column + " IS NOT NULL"
+	}
+
+	op := ">"
+	if key.Desc != before {
+		op = "<"
+	}
+
+	if before {
+		return key.Column + " " + op + " " + b.Param(v)
+	}
+
+	return "(" + key.Column + " " + op + " " + b.Param(v) + " OR " + key.Column + " IS NULL)"
+}
@@ -0,0 +1,178 @@
+package query
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var cursorSort = Sort{
+	{Name: "age", Type: Int},
+	{Name: "score", Type: Float, Desc: true},
+	{Name: "seen", Type: Time},
+	{Name: "name", Type: String},
+}
+
+func TestDecodeCursor(t *testing.T) {
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor Cursor
+		want   []interface{}
+	}{
+		{
+			name:   "values",
+			cursor: Cursor{Sort: cursorSort.String(), Filter: "f", Values: []interface{}{int64(30), 0.5, seen, "ivan"}},
+			want:   []interface{}{int64(30), 0.5, seen, "ivan"},
+		},
+		{
+			name:   "nulls",
+			cursor: Cursor{Sort: cursorSort.String(), Filter: "f", Values: []interface{}{nil, nil, nil, "ivan"}},
+			want:   []interface{}{nil, nil, nil, "ivan"},
+		},
+		{
+			name:   "no position",
+			cursor: Cursor{Sort: cursorSort.String(), Filter: "f", Before: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := DecodeCursor(tt.cursor.Encode(), cursorSort, "f")
+			if err != nil {
+				t.Fatalf("DecodeCursor() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(c.Values, tt.want) {
+				t.Fatalf("DecodeCursor() values = %#v, want %#v", c.Values, tt.want)
+			}
+			if c.Before != tt.cursor.Before {
+				t.Fatalf("DecodeCursor() before = %t, want %t", c.Before, tt.cursor.Before)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	token := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "!!"},
+		{name: "not JSON", token: token(`{"s":`)},
+		{name: "other sort", token: token(`{"s":"age,-score,seen","f":"f","v":[1,2,"2024-01-02T03:04:05Z"]}`)},
+		{name: "other filters", token: token(`{"s":"age,-score,seen,name","f":"g","v":[1,2,"2024-01-02T03:04:05Z","a"]}`)},
+		{name: "missing filters", token: token(`{"s":"age,-score,seen,name","v":[1,2,"2024-01-02T03:04:05Z","a"]}`)},
+		{name: "too few values", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,2,"2024-01-02T03:04:05Z"]}`)},
+		{name: "string for int", token: token(`{"s":"age,-score,seen,name","f":"f","v":["1",2,"2024-01-02T03:04:05Z","a"]}`)},
+		{name: "fraction for int", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1.5,2,"2024-01-02T03:04:05Z","a"]}`)},
+		{name: "bool for float", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,true,"2024-01-02T03:04:05Z","a"]}`)},
+		{name: "invalid time", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,2,"yesterday","a"]}`)},
+		{name: "number for time", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,2,1704164645,"a"]}`)},
+		{name: "number for string", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,2,"2024-01-02T03:04:05Z",1]}`)},
+		{name: "object for string", token: token(`{"s":"age,-score,seen,name","f":"f","v":[1,2,"2024-01-02T03:04:05Z",{}]}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodeCursor(tt.token, cursorSort, "f"); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("DecodeCursor() = %+v, %v; want ErrInvalid", c, err)
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	byAgeID := Sort{{Name: "age", Column: "age"}, {Name: "id", Column: "id"}}
+
+	tests := []struct {
+		name   string
+		sort   Sort
+		values []interface{}
+		before bool
+		sql    string
+		params []interface{}
+	}{
+		{
+			name:   "after",
+			sort:   byAgeID,
+			values: []interface{}{int64(30), int64(5)},
+			sql:    " WHERE (((age > $1 OR age IS NULL)) OR (age = $2 AND (id > $3 OR id IS NULL)))",
+			params: []interface{}{int64(30), int64(30), int64(5)},
+		},
+		{
+			name:   "before",
+			sort:   byAgeID,
+			values: []interface{}{int64(30), int64(5)},
+			before: true,
+			sql:    " WHERE ((age < $1) OR (age = $2 AND id < $3))",
+			params: []interface{}{int64(30), int64(30), int64(5)},
+		},
+		{
+			name:   "descending",
+			sort:   Sort{{Name: "age", Column: "age", Desc: true}, {Name: "id", Column: "id"}},
+			values: []interface{}{int64(30), int64(5)},
+			sql:    " WHERE (((age < $1 OR age IS NULL)) OR (age = $2 AND (id > $3 OR id IS NULL)))",
+			params: []interface{}{int64(30), int64(30), int64(5)},
+		},
+		{
+			name:   "descending before",
+			sort:   Sort{{Name: "age", Column: "age", Desc: true}, {Name: "id", Column: "id"}},
+			values: []interface{}{int64(30), int64(5)},
+			before: true,
+			sql:    " WHERE ((age > $1) OR (age = $2 AND id < $3))",
+			params: []interface{}{int64(30), int64(30), int64(5)},
+		},
+		{
+			name:   "after null",
+			sort:   byAgeID,
+			values: []interface{}{nil, int64(5)},
+			sql:    " WHERE ((age IS NULL AND (id > $1 OR id IS NULL)))",
+			params: []interface{}{int64(5)},
+		},
+		{
+			name:   "before null",
+			sort:   byAgeID,
+			values: []interface{}{nil, int64(5)},
+			before: true,
+			sql:    " WHERE ((age IS NOT NULL) OR (age IS NULL AND id < $1))",
+			params: []interface{}{int64(5)},
+		},
+		{
+			name:   "nothing follows null",
+			sort:   Sort{{Name: "age", Column: "age"}},
+			values: []interface{}{nil},
+			sql:    " WHERE FALSE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{}
+			if err := b.Seek(tt.sort, tt.values, tt.before); err != nil {
+				t.Fatalf("Seek() error = %v", err)
+			}
+
+			if got := b.SQL(); got != tt.sql {
+				t.Errorf("SQL() = %q, want %q", got, tt.sql)
+			}
+			if got := b.Params(); !reflect.DeepEqual(got, tt.params) {
+				t.Errorf("Params() = %v, want %v", got, tt.params)
+			}
+		})
+	}
+}
+
+func TestSeekValuesMismatch(t *testing.T) {
+	b := &Builder{}
+	if err := b.Seek(Sort{{Name: "id", Column: "id"}}, []interface{}{1, 2}, false); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Seek() error = %v, want ErrInvalid", err)
+	}
+}
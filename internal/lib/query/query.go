@@ -0,0 +1,243 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a filterable field, deciding how values are parsed and
+// which operators apply.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	// Time values are RFC 3339 timestamps.
+	Time
+)
+
+type Op string
+
+const (
+	OpEq      Op = "eq"
+	OpIn      Op = "in"
+	OpGt      Op = "gt"
+	OpGte     Op = "gte"
+	OpLt      Op = "lt"
+	OpLte     Op = "lte"
+	OpPrefix  Op = "prefix"
+	OpIPrefix Op = "iprefix"
+	OpIEq     Op = "ieq"
+	OpNull    Op = "null"
+)
+
+var typeOps = map[Type][]Op{
+	String: {OpEq, OpIn, OpPrefix, OpIPrefix, OpIEq, OpNull},
+	Int:    {OpEq, OpIn, OpGt, OpGte, OpLt, OpLte, OpNull},
+	Float:  {OpEq, OpIn, OpGt, OpGte, OpLt, OpLte, OpNull},
+	Time:   {OpEq, OpIn, OpGt, OpGte, OpLt, OpLte, OpNull},
+}
+
+// Field maps a query parameter name to a column.
+type Field struct {
+	// Name is the parameter name, filled in by Parse.
+	Name   string
+	Column string
+	Type   Type
+	// Nullable allows the null operator. Empty strings count as NULL.
+	Nullable bool
+}
+
+type Schema map[string]Field
+
+// Predicate is a single condition, Values holds one parsed value per operand.
+type Predicate struct {
+	Field  Field
+	Op     Op
+	Values []interface{}
+}
+
+// Filter is the parsed form of the filtering parameters. All predicates must
+// hold; if there are groups, additionally all predicates of at least one
+// group must hold.
+type Filter struct {
+	All    []Predicate
+	Groups [][]Predicate
+}
+
+const (
+	groupPrefix = "or."
+	maxValues   = 100
+)
+
+var ErrInvalid = errors.New("invalid filter")
+
+// Parse reads a Filter from the query parameters known to schema. A
+// parameter is a field name with an optional _<op> suffix, e.g. age_gte=30
+// or name_iprefix=iv. Repeated or comma separated values of a plain or _in
+// parameter form an IN list, _null takes true or false. Parameters named
+// or.<group>.<param> form OR groups. params names the other parameters q
+// may hold, such as paging; any further parameter is rejected, so that a
+// misspelt filter does not silently widen the result.
+func Parse(schema Schema, q url.Values, params ...string) (*Filter, error) {
+	f := &Filter{}
+	groups := make(map[string][]Predicate)
+
+	passthrough := make(map[string]bool, len(params))
+	for _, param := range params {
+		passthrough[param] = true
+	}
+
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name, group := key, ""
+
+		if strings.HasPrefix(key, groupPrefix) {
+			rest := strings.TrimPrefix(key, groupPrefix)
+
+			i := strings.Index(rest, ".")
+			if i <= 0 {
+				return nil, fmt.Errorf("%w: invalid group parameter %s", ErrInvalid, key)
+			}
+			group, name = rest[:i], rest[i+1:]
+		}
+
+		field, op, ok := lookup(schema, name)
+		if !ok {
+			if group == "" && passthrough[key] {
+				continue
+			}
+
+			return nil, fmt.Errorf("%w: unknown parameter %s", ErrInvalid, key)
+		}
+
+		p, err := parsePredicate(schema[field], name, op, q[key])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+		}
+		p.Field = schema[field]
+		p.Field.Name = field
+
+		if group == "" {
+			f.All = append(f.All, p)
+		} else {
+			groups[group] = append(groups[group], p)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f.Groups = append(f.Groups, groups[name])
+	}
+
+	return f, nil
+}
+
+// lookup splits name into a schema field and operator. An exact field name
+// wins over a suffix, so fields may themselves contain underscores.
+func lookup(schema Schema, name string) (string, Op, bool) {
+	if _, ok := schema[name]; ok {
+		return name, OpEq, true
+	}
+
+	i := strings.LastIndex(name, "_")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	field, op := name[:i], Op(name[i+1:])
+
+	f, ok := schema[field]
+	if !ok {
+		return "", "", false
+	}
+
+	for _, allowed := range typeOps[f.Type] {
+		if op == allowed && op != OpEq && (op != OpNull || f.Nullable) {
+			return field, op, true
+		}
+	}
+
+	return "", "", false
+}
+
+func parsePredicate(field Field, name string, op Op, raw []string) (Predicate, error) {
+	p := Predicate{Op: op}
+
+	if op == OpNull {
+		if len(raw) != 1 {
+			return p, fmt.Errorf("%s takes a single value", name)
+		}
+
+		isNull, err := strconv.ParseBool(raw[0])
+		if err != nil {
+			return p, fmt.Errorf("invalid %s value", name)
+		}
+		p.Values = []interface{}{isNull}
+
+		return p, nil
+	}
+
+	var values []string
+	switch op {
+	case OpEq, OpIn:
+		for _, v := range raw {
+			values = append(values, strings.Split(v, ",")...)
+		}
+		if len(values) > 1 {
+			p.Op = OpIn
+		}
+	default:
+		if len(raw) != 1 {
+			return p, fmt.Errorf("%s takes a single value", name)
+		}
+		values = raw
+	}
+
+	if len(values) > maxValues {
+		return p, fmt.Errorf("%s takes at most %d values", name, maxValues)
+	}
+
+	for _, v := range values {
+		parsed, err := parseValue(field.Type, strings.TrimSpace(v))
+		if err != nil {
+			return p, fmt.Errorf("invalid %s value", name)
+		}
+		p.Values = append(p.Values, parsed)
+	}
+
+	return p, nil
+}
+
+func parseValue(t Type, v string) (interface{}, error) {
+	switch t {
+	case Int:
+		return strconv.ParseInt(v, 10, 64)
+	case Float:
+		return strconv.ParseFloat(v, 64)
+	case Time:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		if v == "" {
+			return nil, errors.New("empty value")
+		}
+
+		return v, nil
+	}
+}
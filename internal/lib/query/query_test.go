@@ -0,0 +1,114 @@
+package query
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	"name":      {Column: "name", Type: String},
+	"age":       {Column: "age", Type: Int, Nullable: true},
+	"age_count": {Column: "age_count", Type: Int},
+	"score":     {Column: "score", Type: Float},
+	"seen":      {Column: "seen", Type: Time},
+}
+
+func pred(field string, op Op, values ...interface{}) Predicate {
+	f := testSchema[field]
+	f.Name = field
+
+	return Predicate{Field: f, Op: op, Values: values}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query  string
+		params []string
+		want   *Filter
+	}{
+		{query: "", want: &Filter{}},
+		{query: "age=30", want: &Filter{All: []Predicate{pred("age", OpEq, int64(30))}}},
+		{query: "age=30,40", want: &Filter{All: []Predicate{pred("age", OpIn, int64(30), int64(40))}}},
+		{query: "age=30&age=40", want: &Filter{All: []Predicate{pred("age", OpIn, int64(30), int64(40))}}},
+		{query: "age_in=30", want: &Filter{All: []Predicate{pred("age", OpIn, int64(30))}}},
+		{query: "age_gte=18", want: &Filter{All: []Predicate{pred("age", OpGte, int64(18))}}},
+		{query: "age_count=3", want: &Filter{All: []Predicate{pred("age_count", OpEq, int64(3))}}},
+		{query: "age_count_lt=3", want: &Filter{All: []Predicate{pred("age_count", OpLt, int64(3))}}},
+		{query: "age_null=true", want: &Filter{All: []Predicate{pred("age", OpNull, true)}}},
+		{query: "score_gt=0.5", want: &Filter{All: []Predicate{pred("score", OpGt, 0.5)}}},
+		{
+			query: "seen_lt=2024-01-02T03:04:05Z",
+			want:  &Filter{All: []Predicate{pred("seen", OpLt, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))}},
+		},
+		{query: "name_iprefix=iv", want: &Filter{All: []Predicate{pred("name", OpIPrefix, "iv")}}},
+		{
+			query: "score_lt=1&age_gte=18",
+			want:  &Filter{All: []Predicate{pred("age", OpGte, int64(18)), pred("score", OpLt, 1.0)}},
+		},
+		{
+			query: "or.b.name=x&or.a.age_lt=10&or.a.score=1",
+			want: &Filter{Groups: [][]Predicate{
+				{pred("age", OpLt, int64(10)), pred("score", OpEq, 1.0)},
+				{pred("name", OpEq, "x")},
+			}},
+		},
+		{query: "limit=10&sort=age", params: []string{"limit", "sort"}, want: &Filter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Parse(testSchema, q, tt.params...)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		params []string
+	}{
+		{name: "unknown operator", query: "age_gtee=1"},
+		{name: "eq suffix", query: "age_eq=1"},
+		{name: "operator of another type", query: "name_gt=a"},
+		{name: "null on non-nullable field", query: "name_null=true"},
+		{name: "unknown field", query: "nme=x"},
+		{name: "unknown parameter", query: "limit=10"},
+		{name: "unknown parameter next to allowed", query: "limit=10&offset=0", params: []string{"limit"}},
+		{name: "passthrough in group", query: "or.a.limit=10", params: []string{"limit"}},
+		{name: "group without name", query: "or.age=1"},
+		{name: "invalid int", query: "age=abc"},
+		{name: "invalid float", query: "score_gt=x"},
+		{name: "invalid time", query: "seen_gt=yesterday"},
+		{name: "invalid bool", query: "age_null=maybe"},
+		{name: "repeated single value", query: "age_gte=1&age_gte=2"},
+		{name: "empty string", query: "name="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := Parse(testSchema, q, tt.params...); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Parse(%q) = %+v, %v; want ErrInvalid", tt.query, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortKey is one ORDER BY term. Type is the type of the column values,
+// which cursors are checked against.
+type SortKey struct {
+	Name       string
+	Column     string
+	Type       Type
+	Desc       bool
+	NullsFirst bool
+}
+
+type Sort []SortKey
+
+// ParseSort parses a comma separated list of names from columns, each
+// optionally prefixed with "-" for descending order, e.g. "-age,surname".
+// The tiebreaker, which must be unique, is appended in ascending order unless
+// already present, so that the order is total.
+func ParseSort(columns map[string]Field, raw, tiebreaker string) (Sort, error) {
+	var s Sort
+	seen := make(map[string]bool)
+
+	if raw != "" {
+		for _, term := range strings.Split(raw, ",") {
+			// A "+" prefix arrives as a space when it is not escaped.
+			term = strings.TrimLeft(strings.TrimSpace(term), "+")
+
+			key := SortKey{Name: term}
+			if strings.HasPrefix(term, "-") {
+				key.Name, key.Desc = term[1:], true
+			}
+
+			field, ok := columns[key.Name]
+			if !ok {
+				return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalid, key.Name)
+			}
+			if seen[key.Name] {
+				return nil, fmt.Errorf("%w: %s is sorted by twice", ErrInvalid, key.Name)
+			}
+			seen[key.Name] = true
+
+			key.Column, key.Type = field.Column, field.Type
+			s = append(s, key)
+		}
+	}
+
+	if !seen[tiebreaker] {
+		field := columns[tiebreaker]
+		s = append(s, SortKey{Name: tiebreaker, Column: field.Column, Type: field.Type})
+	}
+
+	return s, nil
+}
+
+// SQL returns the ORDER BY clause, including the leading space. Unless
+// reversed, NULLs sort last in either direction.
+func (s Sort) SQL() string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	terms := make([]string, 0, len(s))
+	for _, key := range s {
+		term := key.Column + " ASC"
+		if key.Desc {
+			term = key.Column + " DESC"
+		}
+
+		if key.NullsFirst {
+			term += " NULLS FIRST"
+		} else {
+			term += " NULLS LAST"
+		}
+
+		terms = append(terms, term)
+	}
+
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// Reverse returns the exact opposite order, used to read a page backwards.
+func (s Sort) Reverse() Sort {
+	reversed := make(Sort, len(s))
+	for i, key := range s {
+		key.Desc = !key.Desc
+		key.NullsFirst = !key.NullsFirst
+		reversed[i] = key
+	}
+
+	return reversed
+}
+
+// String formats s the way ParseSort reads it.
+func (s Sort) String() string {
+	terms := make([]string, 0, len(s))
+	for _, key := range s {
+		if key.Desc {
+			terms = append(terms, "-"+key.Name)
+		} else {
+			terms = append(terms, key.Name)
+		}
+	}
+
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,82 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+var testSortColumns = map[string]Field{
+	"id":   {Column: "id", Type: Int},
+	"age":  {Column: "age", Type: Int},
+	"name": {Column: "lower(name)", Type: String},
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		raw      string
+		str      string
+		sql      string
+		reversed string
+	}{
+		{
+			raw:      "",
+			str:      "id",
+			sql:      " ORDER BY id ASC NULLS LAST",
+			reversed: " ORDER BY id DESC NULLS FIRST",
+		},
+		{
+			raw:      "-age,name",
+			str:      "-age,name,id",
+			sql:      " ORDER BY age DESC NULLS LAST, lower(name) ASC NULLS LAST, id ASC NULLS LAST",
+			reversed: " ORDER BY age ASC NULLS FIRST, lower(name) DESC NULLS FIRST, id DESC NULLS FIRST",
+		},
+		{
+			// An unescaped "+" arrives as a space.
+			raw:      " age",
+			str:      "age,id",
+			sql:      " ORDER BY age ASC NULLS LAST, id ASC NULLS LAST",
+			reversed: " ORDER BY age DESC NULLS FIRST, id DESC NULLS FIRST",
+		},
+		{
+			raw:      "+age",
+			str:      "age,id",
+			sql:      " ORDER BY age ASC NULLS LAST, id ASC NULLS LAST",
+			reversed: " ORDER BY age DESC NULLS FIRST, id DESC NULLS FIRST",
+		},
+		{
+			raw:      "-id,age",
+			str:      "-id,age",
+			sql:      " ORDER BY id DESC NULLS LAST, age ASC NULLS LAST",
+			reversed: " ORDER BY id ASC NULLS FIRST, age DESC NULLS FIRST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			s, err := ParseSort(testSortColumns, tt.raw, "id")
+			if err != nil {
+				t.Fatalf("ParseSort() error = %v", err)
+			}
+
+			if got := s.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+			if got := s.SQL(); got != tt.sql {
+				t.Errorf("SQL() = %q, want %q", got, tt.sql)
+			}
+			if got := s.Reverse().SQL(); got != tt.reversed {
+				t.Errorf("Reverse().SQL() = %q, want %q", got, tt.reversed)
+			}
+		})
+	}
+}
+
+func TestParseSortInvalid(t *testing.T) {
+	for _, raw := range []string{"gender", "age,age", "age,-age", "-", "age,"} {
+		t.Run(raw, func(t *testing.T) {
+			if s, err := ParseSort(testSortColumns, raw, "id"); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("ParseSort(%q) = %v, %v; want ErrInvalid", raw, s, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitBurst(t *testing.T) {
+	l := New(1, 3)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() %d = %v, want nil", i, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("burst of 3 took %s, want no waiting", elapsed)
+	}
+}
+
+func TestWaitRefill(t *testing.T) {
+	const rate = 50
+
+	l := New(rate, 1)
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() = %v, want nil", err)
+	}
+
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("second Wait() = %v, want nil", err)
+	}
+
+	// One token takes 1/rate seconds to refill; leave room for timer slack.
+	if elapsed := time.Since(start); elapsed < time.Second/rate-5*time.Millisecond {
+		t.Fatalf("second Wait() returned after %s, want about %s", elapsed, time.Second/rate)
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			want: context.DeadlineExceeded,
+		},
+		{
+			name: "cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+
+				return ctx, cancel
+			},
+			want: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The second token arrives only after 10s.
+			l := New(0.1, 1)
+			if err := l.Wait(context.Background()); err != nil {
+				t.Fatalf("first Wait() = %v, want nil", err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			err := l.Wait(ctx)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Wait() = %v, want %v", err, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("Wait() returned after %s, want soon after cancellation", elapsed)
+			}
+		})
+	}
+}
+
+func TestWaitUnlimited(t *testing.T) {
+	l := New(0, 1)
+
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() %d = %v, want nil", i, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latin follows the common Russian passport-like romanization, with the
+// Ukrainian and Belarusian letters added, e.g. "Дмитрий" becomes "Dmitriy".
+var latin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+}
+
+// ToLatin transliterates the Cyrillic letters of s and keeps everything else.
+// An upper case letter becomes "Zh"-like title case, or "ZH" inside an upper
+// case word.
+func ToLatin(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i, r := range runes {
+		l, ok := latin[unicode.ToLower(r)]
+		if !ok {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		if !unicode.IsUpper(r) || l == "" {
+			b.WriteString(l)
+
+			continue
+		}
+
+		if (i+1 < len(runes) && unicode.IsUpper(runes[i+1])) || (i > 0 && unicode.IsUpper(runes[i-1])) {
+			b.WriteString(strings.ToUpper(l))
+		} else {
+			b.WriteString(strings.ToUpper(l[:1]) + l[1:])
+		}
+	}
+
+	return b.String()
+}
+
+// keyRules fold the spelling variants romanizations disagree on, applied to
+// lower case Latin.
+var keyRules = strings.NewReplacer(
+	"j", "y",
+	"kh", "h",
+	"x", "ks",
+	"yo", "e",
+	"ai", "ay",
+	"ei", "ey",
+	"oi", "oy",
+	"ui", "uy",
+)
+
+// wordEndings fold "-iy", "-ii", "-yi" and "-yy" into "-y", so that
+// "Dmitriy", "Dmitrii" and "Dmitry" agree.
+var wordEndings = []string{"iy", "ii", "yi", "yy"}
+
+// Key returns a comparison key under which the Cyrillic and Latin spellings
+// of a name, and the usual romanization variants, are equal: "Дмитрий",
+// "Dmitriy" and "Dmitrij" all give "dmitry", "Сергей" and "Sergei" give
+// "sergey".
+func Key(s string) string {
+	words := strings.Fields(strings.ToLower(ToLatin(s)))
+
+	for i, w := range words {
+		w = keyRules.Replace(w)
+
+		for _, ending := range wordEndings {
+			if strings.HasSuffix(w, ending) && len(w) > len(ending) {
+				w = strings.TrimSuffix(w, ending) + "y"
+
+				break
+			}
+		}
+
+		words[i] = w
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,53 @@
+package translit
+
+import "testing"
+
+func TestToLatin(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "Дмитрий", want: "Dmitriy"},
+		{in: "Сергей", want: "Sergey"},
+		{in: "Жук", want: "Zhuk"},
+		{in: "ЖУК", want: "ZHUK"},
+		{in: "Ж", want: "Zh"},
+		{in: "Щукин", want: "Shchukin"},
+		{in: "Подъячев", want: "Podyachev"},
+		{in: "ОБЪЁМ", want: "OBEM"},
+		{in: "Фёдор", want: "Fedor"},
+		{in: "Їжак", want: "Yizhak"},
+		{in: "Євген", want: "Yevgen"},
+		{in: "Ivan", want: "Ivan"},
+		{in: "Иван-2 Smith", want: "Ivan-2 Smith"},
+		{in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ToLatin(tt.in); got != tt.want {
+			t.Errorf("ToLatin(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		want string
+		in   []string
+	}{
+		{want: "dmitry", in: []string{"Дмитрий", "Dmitriy", "Dmitrij", "Dmitrii", "Dmitry", "DMITRY"}},
+		{want: "sergey", in: []string{"Сергей", "Sergei", "Sergey"}},
+		{want: "fedor", in: []string{"Фёдор", "Fyodor", "Fedor"}},
+		{want: "aleksey", in: []string{"Алексей", "Alexei", "Aleksey"}},
+		{want: "habib", in: []string{"Хабиб", "Khabib", "Habib"}},
+		{want: "ivan petrov", in: []string{"Иван Петров", "  Ivan   Petrov "}},
+		{want: "iy", in: []string{"iy"}},
+	}
+
+	for _, tt := range tests {
+		for _, in := range tt.in {
+			if got := Key(in); got != tt.want {
+				t.Errorf("Key(%q) = %q, want %q", in, got, tt.want)
+			}
+		}
+	}
+}
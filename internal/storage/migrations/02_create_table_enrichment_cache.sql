@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS enrichment_cache (
+                                     name VARCHAR(100) NOT NULL,
+                                     provider VARCHAR(50) NOT NULL,
+                                     payload JSONB NOT NULL,
+                                     fetched_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                     PRIMARY KEY (name, provider)
+    );
+
+CREATE INDEX IF NOT EXISTS enrichment_cache_fetched_at_idx ON enrichment_cache (fetched_at);
+
+-- +goose Down
+DROP TABLE enrichment_cache;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE persons
+    ALTER COLUMN age DROP NOT NULL,
+    ALTER COLUMN gender DROP NOT NULL,
+    ALTER COLUMN country DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS enrichment_status VARCHAR(16) NOT NULL DEFAULT 'complete',
+    ADD COLUMN IF NOT EXISTS enrichment JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS enriched_at TIMESTAMPTZ;
+
+-- Rows inserted before this migration were always fully enriched.
+ALTER TABLE persons ALTER COLUMN enrichment_status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS persons_enrichment_status_idx ON persons (enrichment_status);
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_enrichment_status_idx;
+
+ALTER TABLE persons
+    DROP COLUMN enriched_at,
+    DROP COLUMN enrichment,
+    DROP COLUMN enrichment_status;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE persons ADD COLUMN IF NOT EXISTS enrichment_claimed_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS persons_enriched_at_idx ON persons (enriched_at NULLS FIRST, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_enriched_at_idx;
+
+ALTER TABLE persons DROP COLUMN enrichment_claimed_until;
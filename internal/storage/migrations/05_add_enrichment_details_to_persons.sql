@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE persons
+    ADD COLUMN IF NOT EXISTS age_count INT,
+    ADD COLUMN IF NOT EXISTS gender_probability DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS gender_count INT,
+    ADD COLUMN IF NOT EXISTS countries JSONB NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS persons_gender_probability_idx ON persons (gender_probability);
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_gender_probability_idx;
+
+ALTER TABLE persons
+    DROP COLUMN countries,
+    DROP COLUMN gender_count,
+    DROP COLUMN gender_probability,
+    DROP COLUMN age_count;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS jobs (
+                                     id BIGSERIAL PRIMARY KEY,
+                                     kind VARCHAR(32) NOT NULL,
+                                     person_id INT NOT NULL REFERENCES persons (id) ON DELETE CASCADE,
+                                     status VARCHAR(16) NOT NULL DEFAULT 'queued',
+                                     error TEXT,
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                     updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+CREATE INDEX IF NOT EXISTS jobs_status_idx ON jobs (status);
+
+-- +goose Down
+DROP TABLE jobs;
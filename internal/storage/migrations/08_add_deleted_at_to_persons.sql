@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE persons ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS persons_deleted_at_idx ON persons (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_deleted_at_idx;
+
+ALTER TABLE persons DROP COLUMN deleted_at;
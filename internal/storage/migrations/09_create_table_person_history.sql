@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS person_history (
+                                     id BIGSERIAL PRIMARY KEY,
+                                     person_id BIGINT NOT NULL,
+                                     action VARCHAR(16) NOT NULL,
+                                     version BIGINT NOT NULL,
+                                     old_value JSONB,
+                                     new_value JSONB NOT NULL,
+                                     actor VARCHAR(255) NOT NULL,
+                                     request_id VARCHAR(255),
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+CREATE INDEX IF NOT EXISTS person_history_person_id_idx ON person_history (person_id, version);
+
+-- +goose Down
+DROP TABLE person_history;
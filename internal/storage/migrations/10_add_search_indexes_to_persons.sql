@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE persons ADD COLUMN IF NOT EXISTS full_name TEXT
+    GENERATED ALWAYS AS (name || ' ' || surname || COALESCE(' ' || NULLIF(patronymic, ''), '')) STORED;
+
+CREATE INDEX IF NOT EXISTS persons_full_name_fts_idx ON persons USING GIN (to_tsvector('simple', full_name));
+
+CREATE INDEX IF NOT EXISTS persons_full_name_trgm_idx ON persons USING GIN (full_name gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_full_name_trgm_idx;
+
+DROP INDEX IF EXISTS persons_full_name_fts_idx;
+
+ALTER TABLE persons DROP COLUMN full_name;
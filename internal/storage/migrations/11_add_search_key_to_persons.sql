@@ -0,0 +1,15 @@
+-- +goose Up
+-- search_key holds translit.Key of the full name and is maintained by the
+-- application, which also fills it in for existing rows on startup.
+ALTER TABLE persons ADD COLUMN IF NOT EXISTS search_key TEXT;
+
+CREATE INDEX IF NOT EXISTS persons_search_key_idx ON persons (search_key);
+
+CREATE INDEX IF NOT EXISTS persons_search_key_trgm_idx ON persons USING GIN (search_key gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS persons_search_key_trgm_idx;
+
+DROP INDEX IF EXISTS persons_search_key_idx;
+
+ALTER TABLE persons DROP COLUMN search_key;
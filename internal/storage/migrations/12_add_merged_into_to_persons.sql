@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE persons ADD COLUMN IF NOT EXISTS merged_into BIGINT;
+
+-- +goose Down
+ALTER TABLE persons DROP COLUMN merged_into;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+                                     key VARCHAR(255) PRIMARY KEY,
+                                     request_hash CHAR(64) NOT NULL,
+                                     status_code INT,
+                                     header JSONB,
+                                     body BYTEA,
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
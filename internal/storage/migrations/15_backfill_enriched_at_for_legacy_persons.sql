@@ -0,0 +1,9 @@
+-- +goose Up
+-- Legacy rows were marked complete by 03 without a timestamp, which made the
+-- re-enrichment worker treat them as never enriched.
+UPDATE persons SET enriched_at = now()
+WHERE enriched_at IS NULL AND enrichment_status = 'complete';
+
+-- +goose Down
+-- The backfilled timestamps cannot be told apart from real ones.
+SELECT 1;
@@ -1,13 +1,23 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/pressly/goose/v3"
 	"person-extender/internal/entity"
+	personfilters "person-extender/internal/lib/api/filters"
+	"person-extender/internal/lib/audit"
+	"person-extender/internal/lib/query"
+	"person-extender/internal/lib/translit"
+	"person-extender/internal/storage"
+	"sort"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Storage struct {
@@ -38,112 +48,410 @@ func New(host, port, user, password, dbname string) (*Storage, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	err = storage.backfillSearchKeys(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return storage, nil
 }
 
-func (s *Storage) SavePerson(person *entity.Person) (int64, error) {
+const backfillBatchSize = 1000
+
+// backfillSearchKeys fills in search_key for rows written before it existed.
+func (s *Storage) backfillSearchKeys(ctx context.Context) error {
+	for {
+		rows, err := s.db.QueryContext(ctx,
+			"SELECT id, name, surname, COALESCE(patronymic, '') FROM persons WHERE search_key IS NULL LIMIT $1", backfillBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var (
+			ids  []int64
+			keys []string
+		)
+		for rows.Next() {
+			p := new(entity.Person)
+			if err := rows.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic); err != nil {
+				rows.Close()
+
+				return err
+			}
+			ids = append(ids, p.ID)
+			keys = append(keys, searchKey(p))
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			_, err := s.db.ExecContext(ctx,
+				"UPDATE persons SET search_key = v.key FROM unnest($1::bigint[], $2::text[]) AS v(id, key) WHERE persons.id = v.id",
+				pq.Array(ids), pq.Array(keys))
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(ids) < backfillBatchSize {
+			return nil
+		}
+	}
+}
+
+// searchKey is the transliteration-insensitive key of the full name, see
+// translit.Key.
+func searchKey(p *entity.Person) string {
+	return translit.Key(p.Name + " " + p.Surname + " " + p.Patronymic)
+}
+
+const personColumns = "id, name, surname, COALESCE(patronymic, ''), age, age_count, gender, gender_probability, gender_count, country, countries, enrichment_status, enrichment, enriched_at, version, deleted_at, merged_into"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func scanPerson(row rowScanner) (*entity.Person, error) {
+	p := new(entity.Person)
+
+	var countries, enrichment []byte
+	err := row.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic, &p.Age, &p.AgeCount, &p.Gender, &p.GenderProbability, &p.GenderCount,
+		&p.Country, &countries, &p.EnrichmentStatus, &enrichment, &p.EnrichedAt, &p.Version, &p.DeletedAt, &p.MergedInto)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(countries, &p.Countries); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(enrichment, &p.Enrichment); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// marshalEnrichment encodes the JSONB columns of person, substituting empty
+// documents for nil values to satisfy the NOT NULL constraints.
+func marshalEnrichment(person *entity.Person) (countries, enrichment []byte, err error) {
+	countries = []byte("[]")
+	if person.Countries != nil {
+		if countries, err = json.Marshal(person.Countries); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	enrichment = []byte("{}")
+	if person.Enrichment != nil {
+		if enrichment, err = json.Marshal(person.Enrichment); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return countries, enrichment, nil
+}
+
+func (s *Storage) SavePerson(ctx context.Context, person *entity.Person) (int64, error) {
 	const op = "storage.postgres.SavePerson"
 
-	stmt, err := s.db.Prepare("INSERT INTO persons (name, surname, patronymic, age, gender, country) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	var id int64
-	err = stmt.QueryRow(person.Name, person.Surname, person.Patronymic, person.Age, person.Gender, person.Country).Scan(&id)
+	saved, err := insertPerson(ctx, tx, person)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	return id, nil
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved.ID, nil
 }
 
-func (s *Storage) DeletePerson(ID int64) error {
-	const op = "storage.postgres.DeletePerson"
+// SaveUniquePerson stores person unless a live person with the same search
+// key exists, in which case it returns storage.ErrDuplicatePerson together
+// with the oldest such person. Concurrent calls for the same key are
+// serialized by a transaction-scoped advisory lock on the key.
+func (s *Storage) SaveUniquePerson(ctx context.Context, person *entity.Person) (*entity.Person, error) {
+	const op = "storage.postgres.SaveUniquePerson"
 
-	_, err := s.db.Exec(`DELETE FROM persons WHERE id = $1`, ID)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	key := searchKey(person)
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	existing, err := findDuplicate(ctx, tx, key)
+	if err == nil {
+		return existing, storage.ErrDuplicatePerson
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	saved, err := insertPerson(ctx, tx, person)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
 }
 
-func (s *Storage) UpdatePerson(person *entity.Person) error {
-	const op = "storage.postgres.UpdatePerson"
+// insertPerson writes a new person and its creation to the history.
+func insertPerson(ctx context.Context, tx *sql.Tx, person *entity.Person) (*entity.Person, error) {
+	status := person.EnrichmentStatus
+	if status == "" {
+		status = entity.EnrichmentPending
+	}
 
-	stmt, err := s.db.Prepare("UPDATE persons SET name = $2, surname = $3, patronymic = $4, age = $5, gender = $6, country = $7 WHERE id = $1")
+	countries, enrichment, err := marshalEnrichment(person)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
 
-	_, err = stmt.Exec(person.ID, person.Name, person.Surname, person.Patronymic, person.Age, person.Gender, person.Country)
+	row := tx.QueryRowContext(ctx, `INSERT INTO persons (name, surname, patronymic, age, age_count, gender, gender_probability, gender_count,
+		country, countries, enrichment_status, enrichment, enriched_at, search_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING `+personColumns,
+		person.Name, person.Surname, person.Patronymic, person.Age, person.AgeCount, person.Gender, person.GenderProbability,
+		person.GenderCount, person.Country, countries, status, enrichment, person.EnrichedAt, searchKey(person))
+
+	saved, err := scanPerson(row)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, err
 	}
-	return nil
+
+	if err := recordHistory(ctx, tx, entity.HistoryCreate, personChange{new: saved}); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
 }
 
-func (s *Storage) GetPersons(filters *entity.Filters, limit, offset int64) ([]*entity.Person, error) {
-	const op = "storage.postgres.GetPersons"
+// DeletePerson soft deletes a person by stamping deleted_at. It returns
+// storage.ErrPersonNotFound if there is no such person or it is already
+// deleted.
+func (s *Storage) DeletePerson(ctx context.Context, ID int64) error {
+	const op = "storage.postgres.DeletePerson"
+
+	_, err := s.changePerson(ctx, ID, entity.HistoryDelete, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt != nil {
+			return nil, storage.ErrPersonNotFound
+		}
 
-	query := "SELECT * FROM persons"
+		row := tx.QueryRowContext(ctx,
+			`UPDATE persons SET deleted_at = now(), version = version + 1 WHERE id = $1 RETURNING `+personColumns, ID)
 
-	conditions := []string{}
-	params := []interface{}{}
-	paramId := 1
+		deleted, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 
-	if filters.Name != nil {
-		conditions = append(conditions, fmt.Sprintf("name = $%d", paramId))
-		params = append(params, *filters.Name)
-		paramId++
+		return deleted, nil
+	})
+
+	return err
+}
+
+// RestorePerson undoes a soft delete, which for a merged source also ends
+// its link to the merge target. It returns storage.ErrNotDeleted if the
+// person exists but is not deleted.
+func (s *Storage) RestorePerson(ctx context.Context, ID int64) (*entity.Person, error) {
+	const op = "storage.postgres.RestorePerson"
+
+	return s.changePerson(ctx, ID, entity.HistoryRestore, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt == nil {
+			return nil, storage.ErrNotDeleted
+		}
+
+		row := tx.QueryRowContext(ctx,
+			`UPDATE persons SET deleted_at = NULL, merged_into = NULL, version = version + 1 WHERE id = $1 RETURNING `+personColumns, ID)
+
+		restored, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return restored, nil
+	})
+}
+
+// PurgeDeletedPersons hard deletes persons soft deleted before deletedBefore.
+// Their history is kept and closed with a purge entry holding the final state.
+func (s *Storage) PurgeDeletedPersons(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	const op = "storage.postgres.PurgeDeletedPersons"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	if filters.Surname != nil {
-		conditions = append(conditions, fmt.Sprintf("surname = $%d", paramId))
-		params = append(params, *filters.Surname)
-		paramId++
+	rows, err := tx.QueryContext(ctx, "DELETE FROM persons WHERE deleted_at < $1 RETURNING "+personColumns, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if filters.Patronymic != nil {
-		conditions = append(conditions, fmt.Sprintf("patronymic = $%d", paramId))
-		params = append(params, *filters.Patronymic)
-		paramId++
+	var changes []personChange
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			rows.Close()
+
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		changes = append(changes, personChange{old: person, new: person})
 	}
+	rows.Close()
 
-	if filters.Age != nil {
-		conditions = append(conditions, fmt.Sprintf("age = $%d", paramId))
-		params = append(params, *filters.Age)
-		paramId++
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if filters.Gender != nil {
-		conditions = append(conditions, fmt.Sprintf("gender = $%d", paramId))
-		params = append(params, *filters.Gender)
-		paramId++
+	if len(changes) > 0 {
+		if err := recordHistory(ctx, tx, entity.HistoryPurge, changes...); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	if filters.Country != nil {
-		conditions = append(conditions, fmt.Sprintf("country = $%d", paramId))
-		params = append(params, *filters.Country)
-		paramId++
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	return int64(len(changes)), nil
+}
+
+// resetEnrichment clears everything enrichment wrote so that a person whose
+// name changed is enriched from scratch.
+const resetEnrichment = `age = NULL, age_count = NULL, gender = NULL, gender_probability = NULL, gender_count = NULL,
+	country = NULL, countries = '[]', enrichment_status = 'pending', enrichment = '{}', enriched_at = NULL`
+
+// UpdatePerson overwrites the person if its stored version still equals
+// expectedVersion and returns the updated row. On a mismatch it returns
+// storage.ErrVersionConflict together with the current row. With reset the
+// enriched fields are cleared instead of taken from person.
+func (s *Storage) UpdatePerson(ctx context.Context, person *entity.Person, expectedVersion int64, reset bool) (*entity.Person, error) {
+	const op = "storage.postgres.UpdatePerson"
+
+	assignments := "age = $5, gender = $6, country = $7"
+	params := []interface{}{person.ID, person.Name, person.Surname, person.Patronymic}
+
+	if reset {
+		assignments = resetEnrichment
+	} else {
+		params = append(params, person.Age, person.Gender, person.Country)
 	}
 
+	return s.changePerson(ctx, person.ID, entity.HistoryUpdate, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt != nil {
+			return nil, storage.ErrPersonNotFound
+		}
+		if old.Version != expectedVersion {
+			return old, storage.ErrVersionConflict
+		}
+
+		row := tx.QueryRowContext(ctx,
+			`UPDATE persons SET name = $2, surname = $3, patronymic = $4, `+assignments+`, version = version + 1
+			WHERE id = $1
+			RETURNING `+personColumns,
+			params...,
+		)
+
+		updated, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return updated, nil
+	})
+}
+
+// whereClause compiles filters into a builder holding the WHERE clause, so
+// that callers can add conditions and parameters of their own.
+func whereClause(filters *entity.Filters) (*query.Builder, error) {
+	b := &query.Builder{}
+
+	f, _, err := personfilters.ToQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.Filter(f); err != nil {
+		return nil, err
+	}
+
+	if filters == nil || !filters.IncludeDeleted {
+		b.Where("deleted_at IS NULL")
+	}
+
+	return b, nil
+}
+
+// orderBy returns the ORDER BY clause for filters, by id if unsorted.
+func orderBy(filters *entity.Filters) (string, error) {
+	_, s, err := personfilters.ToQuery(filters)
+	if err != nil {
+		return "", err
+	}
+
+	if len(s) == 0 {
+		return " ORDER BY id", nil
+	}
+
+	return s.SQL(), nil
+}
+
+func (s *Storage) GetPersons(filters *entity.Filters, limit, offset int64) ([]*entity.Person, error) {
+	const op = "storage.postgres.GetPersons"
+
+	b, err := whereClause(filters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err := orderBy(filters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := "SELECT " + personColumns + " FROM persons" + b.SQL() + order
+
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, params...)
+	rows, err := s.db.Query(query, b.Params()...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	var persons []*entity.Person
 
 	for rows.Next() {
-		p := new(entity.Person)
-		err := rows.Scan(&p.ID, &p.Name, &p.Surname, &p.Patronymic, &p.Age, &p.Gender, &p.Country)
+		p, err := scanPerson(rows)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -156,3 +464,1602 @@ func (s *Storage) GetPersons(filters *entity.Filters, limit, offset int64) ([]*e
 
 	return persons, nil
 }
+
+// CountPersons counts the persons matching filters, ignoring the sort.
+func (s *Storage) CountPersons(ctx context.Context, filters *entity.Filters) (int64, error) {
+	const op = "storage.postgres.CountPersons"
+
+	count, err := countPersons(ctx, s.db, filters)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// PersonStats aggregates the persons matching filters, see entity.PersonStats,
+// reading them all from one snapshot so that the figures agree. bounds are
+// passed to ageHistogram, topCountries to countryStats.
+func (s *Storage) PersonStats(ctx context.Context, filters *entity.Filters, bounds []int64, topCountries int64) (*entity.PersonStats, error) {
+	const op = "storage.postgres.PersonStats"
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stats := &entity.PersonStats{}
+
+	if stats.Total, err = countPersons(ctx, tx, filters); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stats.Ages, stats.UnknownAge, err = ageHistogram(ctx, tx, filters, bounds); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stats.Genders, err = genderStats(ctx, tx, filters); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stats.Countries, err = countryStats(ctx, tx, filters, topCountries); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+func countPersons(ctx context.Context, q querier, filters *entity.Filters) (int64, error) {
+	b, err := whereClause(filters)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM persons"+b.SQL(), b.Params()...).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ageHistogram counts the persons matching filters per age bucket. bounds
+// are the ascending lower bounds of the buckets; ages below the first bound
+// get a bucket of their own starting at 0. Persons without an age are
+// counted separately.
+func ageHistogram(ctx context.Context, q querier, filters *entity.Filters, bounds []int64) ([]*entity.AgeBucket, int64, error) {
+	b, err := whereClause(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	thresholds := b.Param(pq.Array(bounds))
+
+	rows, err := q.QueryContext(ctx,
+		"SELECT width_bucket(age::bigint, "+thresholds+"::bigint[]) AS bucket, COUNT(*) FROM persons"+b.SQL()+" GROUP BY bucket",
+		b.Params()...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	// width_bucket returns 0 below the first bound and i from bounds[i-1].
+	counts := make([]int64, len(bounds)+1)
+
+	var unknown int64
+	for rows.Next() {
+		var (
+			bucket *int64
+			count  int64
+		)
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, 0, err
+		}
+
+		if bucket == nil {
+			unknown = count
+		} else {
+			counts[*bucket] = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	buckets := make([]*entity.AgeBucket, 0, len(counts))
+
+	if len(bounds) > 0 && bounds[0] > 0 {
+		buckets = append(buckets, &entity.AgeBucket{From: 0, To: &bounds[0], Count: counts[0]})
+	}
+
+	for i, from := range bounds {
+		bucket := &entity.AgeBucket{From: from, Count: counts[i+1]}
+		if i+1 < len(bounds) {
+			bucket.To = &bounds[i+1]
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, unknown, nil
+}
+
+// genderStats counts the persons matching filters and averages their age per
+// gender, most common first.
+func genderStats(ctx context.Context, q querier, filters *entity.Filters) ([]*entity.GenderStat, error) {
+	b, err := whereClause(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT NULLIF(gender, '') AS g, COUNT(*), AVG(age)::float8 FROM persons`+b.SQL()+`
+		GROUP BY g
+		ORDER BY COUNT(*) DESC, g NULLS LAST`,
+		b.Params()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*entity.GenderStat{}
+
+	for rows.Next() {
+		stat := &entity.GenderStat{}
+		if err := rows.Scan(&stat.Gender, &stat.Count, &stat.AverageAge); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// countryStats counts the persons matching filters and averages their age
+// for the limit most common countries. Persons without a country are left
+// out.
+func countryStats(ctx context.Context, q querier, filters *entity.Filters, limit int64) ([]*entity.CountryStat, error) {
+	b, err := whereClause(filters)
+	if err != nil {
+		return nil, err
+	}
+	b.Where("NULLIF(country, '') IS NOT NULL")
+	top := b.Param(limit)
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT country, COUNT(*), AVG(age)::float8 FROM persons`+b.SQL()+`
+		GROUP BY country
+		ORDER BY COUNT(*) DESC, country
+		LIMIT `+top,
+		b.Params()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*entity.CountryStat{}
+
+	for rows.Next() {
+		stat := &entity.CountryStat{}
+		if err := rows.Scan(&stat.Country, &stat.Count, &stat.AverageAge); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// SeekPersons returns up to limit persons following, or with before
+// preceding, the row with the sort key values after. Both ways the persons
+// come in filters.Sort order, which must not be empty.
+func (s *Storage) SeekPersons(ctx context.Context, filters *entity.Filters, after []interface{}, before bool, limit int64) ([]*entity.Person, error) {
+	const op = "storage.postgres.SeekPersons"
+
+	b, err := whereClause(filters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, order, err := personfilters.ToQuery(filters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if after != nil {
+		if err := b.Seek(order, after, before); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if before {
+		order = order.Reverse()
+	}
+
+	where := b.SQL()
+	query := "SELECT " + personColumns + " FROM persons" + where + order.SQL() + " LIMIT " + b.Param(limit)
+
+	rows, err := s.db.QueryContext(ctx, query, b.Params()...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	persons := []*entity.Person{}
+
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		persons = append(persons, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if before {
+		for i, j := 0, len(persons)-1; i < j; i, j = i+1, j-1 {
+			persons[i], persons[j] = persons[j], persons[i]
+		}
+	}
+
+	return persons, nil
+}
+
+// GetCachedEnrichment returns the cached payload if it is younger than ttl;
+// found is false otherwise.
+func (s *Storage) GetCachedEnrichment(ctx context.Context, name, provider string, ttl time.Duration) ([]byte, bool, error) {
+	const op = "storage.postgres.GetCachedEnrichment"
+
+	var payload []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT payload FROM enrichment_cache WHERE name = $1 AND provider = $2 AND fetched_at > $3`,
+		name, provider, time.Now().Add(-ttl),
+	).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payload, true, nil
+}
+
+func (s *Storage) SaveCachedEnrichment(ctx context.Context, name, provider string, payload []byte) error {
+	const op = "storage.postgres.SaveCachedEnrichment"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO enrichment_cache (name, provider, payload, fetched_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (name, provider) DO UPDATE SET payload = EXCLUDED.payload, fetched_at = EXCLUDED.fetched_at`,
+		name, provider, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListCachedEnrichments(ctx context.Context, name *string, limit, offset int64) ([]*entity.CacheEntry, error) {
+	const op = "storage.postgres.ListCachedEnrichments"
+
+	query := "SELECT name, provider, payload, fetched_at FROM enrichment_cache"
+	params := []interface{}{}
+
+	if name != nil {
+		query += " WHERE name = $1"
+		params = append(params, *name)
+	}
+
+	query += fmt.Sprintf(" ORDER BY name, provider LIMIT %d OFFSET %d", limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []*entity.CacheEntry
+
+	for rows.Next() {
+		e := new(entity.CacheEntry)
+		err := rows.Scan(&e.Name, &e.Provider, &e.Payload, &e.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// PurgeCachedEnrichments removes cache entries. An empty name matches every
+// name; a non-zero olderThan keeps entries fetched more recently than that.
+func (s *Storage) PurgeCachedEnrichments(ctx context.Context, name string, olderThan time.Duration) (int64, error) {
+	const op = "storage.postgres.PurgeCachedEnrichments"
+
+	query := "DELETE FROM enrichment_cache"
+
+	conditions := []string{}
+	params := []interface{}{}
+	paramId := 1
+
+	if name != "" {
+		conditions = append(conditions, fmt.Sprintf("name = $%d", paramId))
+		params = append(params, name)
+		paramId++
+	}
+
+	if olderThan > 0 {
+		conditions = append(conditions, fmt.Sprintf("fetched_at <= $%d", paramId))
+		params = append(params, time.Now().Add(-olderThan))
+		paramId++
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	res, err := s.db.ExecContext(ctx, query, params...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// ReserveIdempotencyKey claims key for a request with the given body hash
+// for lease. It returns nil if the caller now owns the key, which happens
+// when the key is new, its entry is older than ttl, or the lease of an
+// unfinished request with the same hash has lapsed; otherwise it returns
+// the stored entry. While the owner has not stored a response yet, the
+// entry comes without a status code together with storage.ErrKeyInUse.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (*entity.IdempotentResponse, error) {
+	const op = "storage.postgres.ReserveIdempotencyKey"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, created_at, locked_until)
+		VALUES ($1, $2, now(), now() + make_interval(secs => $4))
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = NULL, header = NULL, body = NULL,
+			created_at = EXCLUDED.created_at, locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.created_at <= $3
+			OR (idempotency_keys.status_code IS NULL AND COALESCE(idempotency_keys.locked_until, idempotency_keys.created_at) < now()
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash)`,
+		key, requestHash, time.Now().Add(-ttl), lease.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if n > 0 {
+		return nil, nil
+	}
+
+	var (
+		stored     entity.IdempotentResponse
+		statusCode *int
+		header     []byte
+	)
+
+	err = s.db.QueryRowContext(ctx,
+		`SELECT request_hash, status_code, header, body FROM idempotency_keys WHERE key = $1`,
+		key,
+	).Scan(&stored.RequestHash, &statusCode, &header, &stored.Body)
+	// A released key was in use a moment ago; the client may simply retry.
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrKeyInUse
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if statusCode == nil {
+		return &stored, storage.ErrKeyInUse
+	}
+
+	stored.StatusCode = *statusCode
+
+	if err := json.Unmarshal(header, &stored.Header); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &stored, nil
+}
+
+// SaveIdempotentResponse stores the response for a key reserved with
+// ReserveIdempotencyKey.
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, key string, response *entity.IdempotentResponse) error {
+	const op = "storage.postgres.SaveIdempotentResponse"
+
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $2, header = $3, body = $4, locked_until = NULL WHERE key = $1`,
+		key, response.StatusCode, header, response.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey gives up a reservation without a stored response, so
+// that a retry runs the request again.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	const op = "storage.postgres.ReleaseIdempotencyKey"
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys removes the keys older than ttl.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error) {
+	const op = "storage.postgres.PurgeIdempotencyKeys"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at <= $1`, time.Now().Add(-ttl))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
+
+// ClaimPersonsForEnrichment leases up to limit persons whose enrichment is
+// missing, incomplete and last tried before retryBefore, or older than
+// staleBefore. Rows locked or leased by another instance are skipped.
+func (s *Storage) ClaimPersonsForEnrichment(ctx context.Context, limit int, retryBefore, staleBefore time.Time, lease time.Duration) ([]*entity.Person, error) {
+	const op = "storage.postgres.ClaimPersonsForEnrichment"
+
+	query := `UPDATE persons SET enrichment_claimed_until = now() + make_interval(secs => $4)
+		WHERE id IN (
+			SELECT id FROM persons
+			WHERE (enriched_at IS NULL
+				OR enriched_at < $3
+				OR (enrichment_status <> $5 AND enriched_at < $2))
+			AND (enrichment_claimed_until IS NULL OR enrichment_claimed_until < now())
+			AND deleted_at IS NULL
+			ORDER BY enriched_at NULLS FIRST, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + personColumns
+
+	rows, err := s.db.QueryContext(ctx, query, limit, retryBefore, staleBefore, lease.Seconds(), entity.EnrichmentComplete)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var persons []*entity.Person
+
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		persons = append(persons, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return persons, nil
+}
+
+// ClaimPersonForEnrichment leases a single person like
+// ClaimPersonsForEnrichment does, whatever its enrichment state. It returns
+// storage.ErrPersonClaimed if the person is locked or leased by someone else.
+func (s *Storage) ClaimPersonForEnrichment(ctx context.Context, ID int64, lease time.Duration) (*entity.Person, error) {
+	const op = "storage.postgres.ClaimPersonForEnrichment"
+
+	row := s.db.QueryRowContext(ctx,
+		`UPDATE persons SET enrichment_claimed_until = now() + make_interval(secs => $2)
+		WHERE id = (
+			SELECT id FROM persons
+			WHERE id = $1
+			AND (enrichment_claimed_until IS NULL OR enrichment_claimed_until < now())
+			AND deleted_at IS NULL
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+personColumns,
+		ID, lease.Seconds(),
+	)
+
+	person, err := scanPerson(row)
+	if err == nil {
+		return person, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM persons WHERE id = $1 AND deleted_at IS NULL)", ID,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return nil, storage.ErrPersonNotFound
+	}
+
+	return nil, storage.ErrPersonClaimed
+}
+
+// ApplyEnrichment stores a fresh enrichment result and releases the claim.
+// Fields left nil keep their previous value. It returns
+// storage.ErrPersonNotFound if the person was deleted during the lookups and
+// storage.ErrVersionConflict if it changed since expectedVersion was read, in
+// which case the result is discarded and only the claim is released.
+func (s *Storage) ApplyEnrichment(ctx context.Context, person *entity.Person, expectedVersion int64) error {
+	const op = "storage.postgres.ApplyEnrichment"
+
+	countries, enrichment, err := marshalEnrichment(person)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.changePerson(ctx, person.ID, entity.HistoryEnrich, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt != nil {
+			return nil, storage.ErrPersonNotFound
+		}
+		if old.Version != expectedVersion {
+			return nil, storage.ErrVersionConflict
+		}
+
+		// Details only move together with the field they describe.
+		row := tx.QueryRowContext(ctx,
+			`UPDATE persons SET
+				age_count = CASE WHEN $2::INT IS NULL THEN age_count ELSE $3 END,
+				age = COALESCE($2, age),
+				gender_probability = CASE WHEN $4::VARCHAR IS NULL THEN gender_probability ELSE $5 END,
+				gender_count = CASE WHEN $4::VARCHAR IS NULL THEN gender_count ELSE $6 END,
+				gender = COALESCE($4, gender),
+				countries = CASE WHEN $7::VARCHAR IS NULL THEN countries ELSE $8 END,
+				country = COALESCE($7, country),
+				enrichment_status = $9, enrichment = $10, enriched_at = now(), enrichment_claimed_until = NULL,
+				version = version + 1
+			WHERE id = $1 AND version = $11
+			RETURNING `+personColumns,
+			person.ID, person.Age, person.AgeCount, person.Gender, person.GenderProbability, person.GenderCount,
+			person.Country, countries, person.EnrichmentStatus, enrichment, expectedVersion,
+		)
+
+		enriched, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return enriched, nil
+	})
+	if errors.Is(err, storage.ErrVersionConflict) {
+		// The stale result is dropped; the row stays eligible for the next run.
+		if _, err := s.db.ExecContext(ctx, "UPDATE persons SET enrichment_claimed_until = NULL WHERE id = $1", person.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return storage.ErrVersionConflict
+	}
+
+	return err
+}
+
+func (s *Storage) GetPerson(ctx context.Context, ID int64) (*entity.Person, error) {
+	const op = "storage.postgres.GetPerson"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+personColumns+" FROM persons WHERE id = $1 AND deleted_at IS NULL", ID)
+
+	person, err := scanPerson(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPersonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return person, nil
+}
+
+const jobColumns = "id, kind, person_id, status, COALESCE(error, ''), created_at, updated_at"
+
+func scanJob(row rowScanner) (*entity.Job, error) {
+	j := new(entity.Job)
+
+	err := row.Scan(&j.ID, &j.Kind, &j.PersonID, &j.Status, &j.Error, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (s *Storage) CreateJob(ctx context.Context, kind string, personID int64) (*entity.Job, error) {
+	const op = "storage.postgres.CreateJob"
+
+	row := s.db.QueryRowContext(ctx,
+		"INSERT INTO jobs (kind, person_id, status) VALUES ($1, $2, $3) RETURNING "+jobColumns,
+		kind, personID, entity.JobQueued,
+	)
+
+	job, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return job, nil
+}
+
+func (s *Storage) GetJob(ctx context.Context, ID int64) (*entity.Job, error) {
+	const op = "storage.postgres.GetJob"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", ID)
+
+	job, err := scanJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return job, nil
+}
+
+func (s *Storage) UpdateJobStatus(ctx context.Context, ID int64, status, errMsg string) error {
+	const op = "storage.postgres.UpdateJobStatus"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE jobs SET status = $2, error = NULLIF($3, ''), updated_at = now() WHERE id = $1",
+		ID, status, errMsg,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return storage.ErrJobNotFound
+	}
+
+	return nil
+}
+
+// ListUnfinishedJobs returns queued and running jobs of kind that have not
+// been touched since updatedBefore, oldest first.
+func (s *Storage) ListUnfinishedJobs(ctx context.Context, kind string, updatedBefore time.Time) ([]*entity.Job, error) {
+	const op = "storage.postgres.ListUnfinishedJobs"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+jobColumns+" FROM jobs WHERE kind = $1 AND status IN ($2, $3) AND updated_at < $4 ORDER BY id",
+		kind, entity.JobQueued, entity.JobRunning, updatedBefore,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var jobs []*entity.Job
+
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jobs, nil
+}
+
+// maxBatchSize caps the rows inserted in one transaction. Batches travel as
+// one array parameter per column, so the bind parameter limit does not apply.
+const maxBatchSize = 5000
+
+// SavePersons inserts persons in batches of batchSize rows, each batch in its
+// own transaction. IDs are returned in input order; persons of a failed batch
+// get ID 0 and the batch error in errs at the same index. With unique, a
+// person whose search key matches a live person or an earlier person of the
+// input is not inserted: it gets storage.ErrDuplicatePerson and the ID of
+// that person, with the keys locked like in SaveUniquePerson.
+func (s *Storage) SavePersons(ctx context.Context, persons []*entity.Person, batchSize int, unique bool) (ids []int64, errs []error) {
+	const op = "storage.postgres.SavePersons"
+
+	ids = make([]int64, len(persons))
+	errs = make([]error, len(persons))
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if batchSize > maxBatchSize {
+		batchSize = maxBatchSize
+	}
+
+	for start := 0; start < len(persons); start += batchSize {
+		end := start + batchSize
+		if end > len(persons) {
+			end = len(persons)
+		}
+
+		batchIDs, batchErrs, err := s.savePersonsBatch(ctx, persons[start:end], unique)
+		if err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = fmt.Errorf("%s: %w", op, err)
+			}
+
+			continue
+		}
+
+		copy(ids[start:end], batchIDs)
+		copy(errs[start:end], batchErrs)
+	}
+
+	return ids, errs
+}
+
+// savePersonsBatch inserts persons from column arrays. The IDs are drawn
+// before the insert, so that every returned row can be matched to the
+// ordinal of its input row. With unique, duplicates are left out and
+// reported in the returned errors.
+func (s *Storage) savePersonsBatch(ctx context.Context, persons []*entity.Person, unique bool) ([]int64, []error, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(persons))
+	errs := make([]error, len(persons))
+
+	// firstOf maps a duplicate within the batch to the row it repeats.
+	firstOf := make(map[int]int)
+	insert := make([]int, 0, len(persons))
+
+	if unique {
+		existing, err := lockSearchKeys(ctx, tx, persons)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		seen := make(map[string]int, len(persons))
+		for i, person := range persons {
+			key := searchKey(person)
+
+			if id, ok := existing[key]; ok {
+				ids[i], errs[i] = id, storage.ErrDuplicatePerson
+				continue
+			}
+			if j, ok := seen[key]; ok {
+				firstOf[i], errs[i] = j, storage.ErrDuplicatePerson
+				continue
+			}
+
+			seen[key] = i
+			insert = append(insert, i)
+		}
+	} else {
+		for i := range persons {
+			insert = append(insert, i)
+		}
+	}
+
+	if len(insert) == 0 {
+		return ids, errs, nil
+	}
+
+	var (
+		names, surnames, patronymics, statuses, keys []string
+		ages, ageCounts, genderCounts                []*int64
+		genders, countries                           []*string
+		genderProbabilities                          []*float64
+		candidates, enrichments                      []string
+		enrichedAts                                  []*string
+	)
+
+	for _, i := range insert {
+		person := persons[i]
+
+		status := person.EnrichmentStatus
+		if status == "" {
+			status = entity.EnrichmentPending
+		}
+
+		countriesJSON, enrichment, err := marshalEnrichment(person)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var enrichedAt *string
+		if person.EnrichedAt != nil {
+			v := person.EnrichedAt.Format(time.RFC3339Nano)
+			enrichedAt = &v
+		}
+
+		names = append(names, person.Name)
+		surnames = append(surnames, person.Surname)
+		patronymics = append(patronymics, person.Patronymic)
+		ages = append(ages, person.Age)
+		ageCounts = append(ageCounts, person.AgeCount)
+		genders = append(genders, person.Gender)
+		genderProbabilities = append(genderProbabilities, person.GenderProbability)
+		genderCounts = append(genderCounts, person.GenderCount)
+		countries = append(countries, person.Country)
+		candidates = append(candidates, string(countriesJSON))
+		statuses = append(statuses, status)
+		enrichments = append(enrichments, string(enrichment))
+		enrichedAts = append(enrichedAts, enrichedAt)
+		keys = append(keys, searchKey(person))
+	}
+
+	rows, err := tx.QueryContext(ctx, `WITH input AS (
+			SELECT nextval(pg_get_serial_sequence('persons', 'id')) AS id, v.*
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::int[], $6::text[], $7::float8[], $8::int[],
+				$9::text[], $10::jsonb[], $11::text[], $12::jsonb[], $13::timestamptz[], $14::text[])
+				WITH ORDINALITY AS v(name, surname, patronymic, age, age_count, gender, gender_probability, gender_count,
+					country, countries, enrichment_status, enrichment, enriched_at, search_key, ord)
+		), inserted AS (
+			INSERT INTO persons (id, name, surname, patronymic, age, age_count, gender, gender_probability, gender_count,
+				country, countries, enrichment_status, enrichment, enriched_at, search_key)
+			SELECT id, name, surname, patronymic, age, age_count, gender, gender_probability, gender_count,
+				country, countries, enrichment_status, enrichment, enriched_at, search_key
+			FROM input
+			RETURNING `+personColumns+`
+		)
+		SELECT inserted.*, input.ord FROM inserted JOIN input USING (id)`,
+		pq.Array(names), pq.Array(surnames), pq.Array(patronymics), pq.Array(ages), pq.Array(ageCounts), pq.Array(genders),
+		pq.Array(genderProbabilities), pq.Array(genderCounts), pq.Array(countries), pq.Array(candidates), pq.Array(statuses),
+		pq.Array(enrichments), pq.Array(enrichedAts), pq.Array(keys),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	saved := make([]*entity.Person, len(insert))
+
+	for rows.Next() {
+		var ord int64
+
+		p, err := scanPerson(keyedRow{rowScanner: rows, key: &ord})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ord < 1 || ord > int64(len(saved)) || saved[ord-1] != nil {
+			return nil, nil, fmt.Errorf("unexpected ordinal %d", ord)
+		}
+		saved[ord-1] = p
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := rows.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	changes := make([]personChange, 0, len(saved))
+
+	for k, p := range saved {
+		if p == nil {
+			return nil, nil, fmt.Errorf("row %d was not inserted", insert[k]+1)
+		}
+		ids[insert[k]] = p.ID
+		changes = append(changes, personChange{new: p})
+	}
+
+	for i, j := range firstOf {
+		ids[i] = ids[j]
+	}
+
+	if err := recordHistory(ctx, tx, entity.HistoryCreate, changes...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return ids, errs, nil
+}
+
+// lockSearchKeys takes the advisory locks of SaveUniquePerson for the search
+// keys of persons, in a fixed order so that concurrent batches cannot
+// deadlock, and returns the oldest live person ID for every key in use.
+func lockSearchKeys(ctx context.Context, tx *sql.Tx, persons []*entity.Person) (map[string]int64, error) {
+	keys := make([]string, len(persons))
+	for i, person := range persons {
+		keys[i] = searchKey(person)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`SELECT pg_advisory_xact_lock(h)
+		FROM (SELECT DISTINCT hashtext(k) AS h FROM unnest($1::text[]) AS k ORDER BY h) locks`,
+		pq.Array(keys),
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT DISTINCT ON (search_key) search_key, id FROM persons
+		WHERE search_key = ANY($1) AND deleted_at IS NULL
+		ORDER BY search_key, id`,
+		pq.Array(keys),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]int64)
+	for rows.Next() {
+		var (
+			key string
+			id  int64
+		)
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, err
+		}
+		existing[key] = id
+	}
+
+	return existing, rows.Err()
+}
+
+const exportFetchSize = 1000
+
+// ExportPersons streams every person matching filters to fn through a
+// server-side cursor, so memory use does not grow with the result set.
+func (s *Storage) ExportPersons(ctx context.Context, filters *entity.Filters, fn func(*entity.Person) error) error {
+	const op = "storage.postgres.ExportPersons"
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	b, err := whereClause(filters)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err := orderBy(filters)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DECLARE persons_export NO SCROLL CURSOR FOR SELECT "+personColumns+" FROM persons"+b.SQL()+order, b.Params()...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		n, err := fetchPersons(ctx, tx, fn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "CLOSE persons_export"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func fetchPersons(ctx context.Context, tx *sql.Tx, fn func(*entity.Person) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH %d FROM persons_export", exportFetchSize))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int
+
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return n, err
+		}
+
+		if err := fn(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, rows.Err()
+}
+
+// patchableColumns are the person columns PatchPerson may write.
+var patchableColumns = map[string]bool{
+	"name":       true,
+	"surname":    true,
+	"patronymic": true,
+	"age":        true,
+	"gender":     true,
+	"country":    true,
+}
+
+// PatchPerson updates only the given columns, provided the stored version
+// still equals expectedVersion. Conflicts and reset are handled like in
+// UpdatePerson.
+func (s *Storage) PatchPerson(ctx context.Context, ID int64, fields map[string]interface{}, expectedVersion int64, reset bool) (*entity.Person, error) {
+	const op = "storage.postgres.PatchPerson"
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !patchableColumns[column] {
+			return nil, fmt.Errorf("%s: column %q cannot be patched", op, column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	assignments := []string{"version = version + 1"}
+	params := []interface{}{ID}
+
+	for _, column := range columns {
+		// resetEnrichment already assigns the enriched columns.
+		if reset && (column == "age" || column == "gender" || column == "country") {
+			continue
+		}
+
+		params = append(params, fields[column])
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(params)))
+	}
+
+	if reset {
+		assignments = append(assignments, resetEnrichment)
+	}
+
+	return s.changePerson(ctx, ID, entity.HistoryUpdate, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt != nil {
+			return nil, storage.ErrPersonNotFound
+		}
+		if old.Version != expectedVersion {
+			return old, storage.ErrVersionConflict
+		}
+
+		row := tx.QueryRowContext(ctx,
+			"UPDATE persons SET "+strings.Join(assignments, ", ")+" WHERE id = $1 RETURNING "+personColumns,
+			params...,
+		)
+
+		updated, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return updated, nil
+	})
+}
+
+// changePerson locks the person ID, lets change write it within the same
+// transaction and records the result in person_history. Errors and rows
+// returned by change are passed through unrecorded.
+func (s *Storage) changePerson(ctx context.Context, ID int64, action string, change func(tx *sql.Tx, old *entity.Person) (*entity.Person, error)) (*entity.Person, error) {
+	const op = "storage.postgres.changePerson"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	old, err := scanPerson(tx.QueryRowContext(ctx, "SELECT "+personColumns+" FROM persons WHERE id = $1 FOR UPDATE", ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPersonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	changed, err := change(tx, old)
+	if err != nil {
+		return changed, err
+	}
+
+	if key := searchKey(changed); key != searchKey(old) {
+		if _, err := tx.ExecContext(ctx, "UPDATE persons SET search_key = $2 WHERE id = $1", ID, key); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := recordHistory(ctx, tx, action, personChange{old: old, new: changed}); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changed, nil
+}
+
+type personChange struct {
+	old, new *entity.Person
+}
+
+// recordHistory writes one person_history row per change, attributed to the
+// actor and request found in ctx.
+func recordHistory(ctx context.Context, tx *sql.Tx, action string, changes ...personChange) error {
+	meta := audit.FromContext(ctx)
+
+	var requestID interface{}
+	if meta.RequestID != "" {
+		requestID = meta.RequestID
+	}
+
+	personIDs := make([]int64, 0, len(changes))
+	versions := make([]int64, 0, len(changes))
+	oldValues := make([]*string, 0, len(changes))
+	newValues := make([]string, 0, len(changes))
+
+	for _, change := range changes {
+		var oldValue *string
+		if change.old != nil {
+			b, err := json.Marshal(change.old)
+			if err != nil {
+				return err
+			}
+			v := string(b)
+			oldValue = &v
+		}
+
+		newValue, err := json.Marshal(change.new)
+		if err != nil {
+			return err
+		}
+
+		personIDs = append(personIDs, change.new.ID)
+		versions = append(versions, change.new.Version)
+		oldValues = append(oldValues, oldValue)
+		newValues = append(newValues, string(newValue))
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO person_history (person_id, action, version, old_value, new_value, actor, request_id)
+		SELECT v.person_id, $2, v.version, v.old_value, v.new_value, $6, $7
+		FROM unnest($1::bigint[], $3::bigint[], $4::jsonb[], $5::jsonb[]) WITH ORDINALITY AS v(person_id, version, old_value, new_value, ord)
+		ORDER BY v.ord`,
+		pq.Array(personIDs), action, pq.Array(versions), pq.Array(oldValues), pq.Array(newValues), meta.Actor, requestID)
+
+	return err
+}
+
+// GetPersonHistory returns the changes of a person, oldest first. Deleted and
+// purged persons keep their history.
+func (s *Storage) GetPersonHistory(ctx context.Context, ID int64) ([]*entity.HistoryEntry, error) {
+	const op = "storage.postgres.GetPersonHistory"
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM persons WHERE id = $1) OR EXISTS (SELECT 1 FROM person_history WHERE person_id = $1)", ID,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return nil, storage.ErrPersonNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, person_id, action, version, old_value, new_value, actor, COALESCE(request_id, ''), created_at
+		FROM person_history WHERE person_id = $1 ORDER BY id`, ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	entries := []*entity.HistoryEntry{}
+
+	for rows.Next() {
+		e := new(entity.HistoryEntry)
+
+		var oldValue, newValue []byte
+		err := rows.Scan(&e.ID, &e.PersonID, &e.Action, &e.Version, &oldValue, &newValue, &e.Actor, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &e.Old); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if err := json.Unmarshal(newValue, &e.New); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// RevertPerson writes the person back to the state it had at version, which
+// must be in its history, as a new version. Conflicts are handled like in
+// UpdatePerson.
+func (s *Storage) RevertPerson(ctx context.Context, ID, version, expectedVersion int64) (*entity.Person, error) {
+	const op = "storage.postgres.RevertPerson"
+
+	return s.changePerson(ctx, ID, entity.HistoryRevert, func(tx *sql.Tx, old *entity.Person) (*entity.Person, error) {
+		if old.DeletedAt != nil {
+			return nil, storage.ErrPersonNotFound
+		}
+		if old.Version != expectedVersion {
+			return old, storage.ErrVersionConflict
+		}
+
+		var snapshot []byte
+		err := tx.QueryRowContext(ctx,
+			"SELECT new_value FROM person_history WHERE person_id = $1 AND version = $2 ORDER BY id DESC LIMIT 1",
+			ID, version,
+		).Scan(&snapshot)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrVersionNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var target entity.Person
+		if err := json.Unmarshal(snapshot, &target); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		status := target.EnrichmentStatus
+		if status == "" {
+			status = entity.EnrichmentPending
+		}
+
+		countries, enrichment, err := marshalEnrichment(&target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		row := tx.QueryRowContext(ctx,
+			`UPDATE persons SET name = $2, surname = $3, patronymic = $4, age = $5, age_count = $6, gender = $7,
+				gender_probability = $8, gender_count = $9, country = $10, countries = $11, enrichment_status = $12,
+				enrichment = $13, enriched_at = $14, version = version + 1
+			WHERE id = $1
+			RETURNING `+personColumns,
+			ID, target.Name, target.Surname, target.Patronymic, target.Age, target.AgeCount, target.Gender,
+			target.GenderProbability, target.GenderCount, target.Country, countries, status, enrichment, target.EnrichedAt,
+		)
+
+		reverted, err := scanPerson(row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return reverted, nil
+	})
+}
+
+// scoredRow scans a person followed by a score column.
+type scoredRow struct {
+	rowScanner
+	score *float64
+}
+
+func (r scoredRow) Scan(dest ...interface{}) error {
+	return r.rowScanner.Scan(append(dest, r.score)...)
+}
+
+// SearchPersons finds live persons whose full name matches q in full-text
+// search or is trigram-similar to it (see pg_trgm's <% operator), best
+// matches first. Similarity is also measured between the transliteration
+// keys, so Cyrillic and Latin spellings find each other. The score adds the
+// full-text rank to the best word similarity.
+func (s *Storage) SearchPersons(ctx context.Context, q string, limit, offset int64) ([]*entity.PersonMatch, error) {
+	const op = "storage.postgres.SearchPersons"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+personColumns+`, score FROM (
+			SELECT *, ts_rank(to_tsvector('simple', full_name), plainto_tsquery('simple', $1))
+				+ GREATEST(word_similarity($1, full_name), word_similarity($2, COALESCE(search_key, ''))) AS score
+			FROM persons
+			WHERE deleted_at IS NULL
+				AND (to_tsvector('simple', full_name) @@ plainto_tsquery('simple', $1) OR $1 <% full_name OR $2 <% search_key)
+		) matches
+		ORDER BY score DESC, id
+		LIMIT $3 OFFSET $4`,
+		q, translit.Key(q), limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	matches := []*entity.PersonMatch{}
+
+	for rows.Next() {
+		m := new(entity.PersonMatch)
+
+		m.Person, err = scanPerson(scoredRow{rowScanner: rows, score: &m.Score})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		matches = append(matches, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return matches, nil
+}
+
+// FindDuplicate returns the oldest live person with the same search key as
+// person, or storage.ErrPersonNotFound.
+func (s *Storage) FindDuplicate(ctx context.Context, person *entity.Person) (*entity.Person, error) {
+	const op = "storage.postgres.FindDuplicate"
+
+	duplicate, err := findDuplicate(ctx, s.db, searchKey(person))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPersonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return duplicate, nil
+}
+
+func findDuplicate(ctx context.Context, q querier, key string) (*entity.Person, error) {
+	row := q.QueryRowContext(ctx,
+		"SELECT "+personColumns+" FROM persons WHERE search_key = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1", key)
+
+	return scanPerson(row)
+}
+
+// ListDuplicates returns clusters of live persons sharing a search key,
+// largest first.
+func (s *Storage) ListDuplicates(ctx context.Context, limit, offset int64) ([]*entity.DuplicateCluster, error) {
+	const op = "storage.postgres.ListDuplicates"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+personColumns+`, key FROM (
+			SELECT *, search_key AS key, COUNT(*) OVER (PARTITION BY search_key) AS size
+			FROM persons
+			WHERE deleted_at IS NULL AND search_key IN (
+				SELECT search_key FROM persons
+				WHERE deleted_at IS NULL AND search_key <> ''
+				GROUP BY search_key
+				HAVING COUNT(*) > 1
+				ORDER BY COUNT(*) DESC, search_key
+				LIMIT $1 OFFSET $2
+			)
+		) duplicates
+		ORDER BY size DESC, key, id`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	clusters := []*entity.DuplicateCluster{}
+
+	for rows.Next() {
+		var key string
+
+		p, err := scanPerson(keyedRow{rowScanner: rows, key: &key})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if len(clusters) == 0 || clusters[len(clusters)-1].Key != key {
+			clusters = append(clusters, &entity.DuplicateCluster{Key: key})
+		}
+		cluster := clusters[len(clusters)-1]
+		cluster.Persons = append(cluster.Persons, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return clusters, nil
+}
+
+// keyedRow scans a person followed by one more column into key.
+type keyedRow struct {
+	rowScanner
+	key interface{}
+}
+
+func (r keyedRow) Scan(dest ...interface{}) error {
+	return r.rowScanner.Scan(append(dest, r.key)...)
+}
+
+// MergePersons folds sources into target in one transaction. Fields missing
+// on target are taken from the first source that has them and the
+// enrichment state is recomputed from all of them, then the sources are
+// soft deleted with merged_into pointing at target. Every person touched
+// gets a HistoryMerge entry.
+func (s *Storage) MergePersons(ctx context.Context, targetID int64, sourceIDs []int64) (*entity.Person, error) {
+	const op = "storage.postgres.MergePersons"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	ids := append([]int64{targetID}, sourceIDs...)
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+personColumns+" FROM persons WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE", pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	locked := make(map[int64]*entity.Person, len(ids))
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			rows.Close()
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		locked[p.ID] = p
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, id := range ids {
+		if locked[id] == nil {
+			return nil, fmt.Errorf("%s: person %d: %w", op, id, storage.ErrPersonNotFound)
+		}
+	}
+
+	old := locked[targetID]
+	merged := *old
+
+	// The provider map is shared with old, which goes to the history as is.
+	merged.Enrichment = make(map[string]string, len(old.Enrichment))
+	for provider, status := range old.Enrichment {
+		merged.Enrichment[provider] = status
+	}
+
+	for _, id := range sourceIDs {
+		mergeInto(&merged, locked[id])
+	}
+
+	if len(merged.Enrichment) > 0 {
+		merged.EnrichmentStatus = entity.EnrichmentState(merged.Enrichment)
+	}
+
+	countries, enrichment, err := marshalEnrichment(&merged)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	target, err := scanPerson(tx.QueryRowContext(ctx,
+		`UPDATE persons SET patronymic = $2, age = $3, age_count = $4, gender = $5, gender_probability = $6, gender_count = $7,
+			country = $8, countries = $9, search_key = $10, enrichment_status = $11, enrichment = $12, enriched_at = $13,
+			version = version + 1
+		WHERE id = $1
+		RETURNING `+personColumns,
+		targetID, merged.Patronymic, merged.Age, merged.AgeCount, merged.Gender, merged.GenderProbability, merged.GenderCount,
+		merged.Country, countries, searchKey(&merged), merged.EnrichmentStatus, enrichment, merged.EnrichedAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	changes := []personChange{{old: old, new: target}}
+
+	for _, id := range sourceIDs {
+		source, err := scanPerson(tx.QueryRowContext(ctx,
+			`UPDATE persons SET deleted_at = now(), merged_into = $2, version = version + 1 WHERE id = $1 RETURNING `+personColumns,
+			id, targetID,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		changes = append(changes, personChange{old: locked[id], new: source})
+	}
+
+	if err := recordHistory(ctx, tx, entity.HistoryMerge, changes...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return target, nil
+}
+
+// mergeInto fills the fields of target that are unset from source. Enriched
+// details only move together with the field they describe. A provider counts
+// as succeeded if it did for either person, and the later enrichment time
+// wins.
+func mergeInto(target, source *entity.Person) {
+	if target.Patronymic == "" {
+		target.Patronymic = source.Patronymic
+	}
+	if target.Age == nil && source.Age != nil {
+		target.Age, target.AgeCount = source.Age, source.AgeCount
+	}
+	if target.Gender == nil && source.Gender != nil {
+		target.Gender, target.GenderProbability, target.GenderCount = source.Gender, source.GenderProbability, source.GenderCount
+	}
+	if target.Country == nil && source.Country != nil {
+		target.Country, target.Countries = source.Country, source.Countries
+	}
+
+	for provider, status := range source.Enrichment {
+		if target.Enrichment[provider] != entity.ProviderOK {
+			target.Enrichment[provider] = status
+		}
+	}
+	if source.EnrichedAt != nil && (target.EnrichedAt == nil || source.EnrichedAt.After(*target.EnrichedAt)) {
+		target.EnrichedAt = source.EnrichedAt
+	}
+}
@@ -0,0 +1,14 @@
+package storage
+
+import "errors"
+
+var (
+	ErrPersonNotFound  = errors.New("person not found")
+	ErrJobNotFound     = errors.New("job not found")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrNotDeleted      = errors.New("person is not deleted")
+	ErrPersonClaimed   = errors.New("person is claimed for enrichment")
+	ErrVersionNotFound = errors.New("version not found")
+	ErrKeyInUse        = errors.New("idempotency key in use")
+	ErrDuplicatePerson = errors.New("duplicate person")
+)
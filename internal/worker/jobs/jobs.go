@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"sync"
+	"time"
+)
+
+var ErrPoolClosed = errors.New("job pool is closed")
+
+type Config struct {
+	Workers      int
+	QueueSize    int
+	RecoverAfter time.Duration
+	Lease        time.Duration
+}
+
+type JobStorage interface {
+	CreateJob(ctx context.Context, kind string, personID int64) (*entity.Job, error)
+	UpdateJobStatus(ctx context.Context, ID int64, status, errMsg string) error
+	ListUnfinishedJobs(ctx context.Context, kind string, updatedBefore time.Time) ([]*entity.Job, error)
+	ClaimPersonForEnrichment(ctx context.Context, ID int64, lease time.Duration) (*entity.Person, error)
+	ApplyEnrichment(ctx context.Context, person *entity.Person, expectedVersion int64) error
+}
+
+type PersonEnricher interface {
+	Enrich(ctx context.Context, name string) (*api.PersonExtends, error)
+}
+
+// Pool runs enrichment jobs for persons created asynchronously.
+type Pool struct {
+	log        *slog.Logger
+	jobStorage JobStorage
+	enricher   PersonEnricher
+	cfg        Config
+
+	queue  chan *entity.Job
+	mu     sync.RWMutex
+	closed bool
+
+	// inflight holds the jobs queued or running in this process, so that
+	// recover does not hand them out a second time.
+	inflightMu sync.Mutex
+	inflight   map[int64]struct{}
+}
+
+func New(log *slog.Logger, jobStorage JobStorage, enricher PersonEnricher, cfg Config) *Pool {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 1
+	}
+
+	return &Pool{
+		log:        log.With(slog.String("component", "worker/jobs")),
+		jobStorage: jobStorage,
+		enricher:   enricher,
+		cfg:        cfg,
+		queue:      make(chan *entity.Job, cfg.QueueSize),
+		inflight:   make(map[int64]struct{}),
+	}
+}
+
+// Enqueue records an enrichment job for personID and hands it to the pool.
+// It blocks while the queue is full.
+func (p *Pool) Enqueue(ctx context.Context, personID int64) (*entity.Job, error) {
+	const op = "worker.jobs.Enqueue"
+
+	job, err := p.jobStorage.CreateJob(ctx, entity.JobKindEnrich, personID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.submit(ctx, job); err != nil {
+		// The person stays pending, so the re-enrichment worker still gets to it.
+		if err := p.jobStorage.UpdateJobStatus(context.WithoutCancel(ctx), job.ID, entity.JobFailed, err.Error()); err != nil {
+			p.log.Error("failed to mark job as failed", slog.String("op", op), slog.Int64("job_id", job.ID), sl.Err(err))
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// submit queues job unless it is already in flight, in which case it
+// reports false.
+func (p *Pool) submit(ctx context.Context, job *entity.Job) (bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false, ErrPoolClosed
+	}
+
+	p.inflightMu.Lock()
+	_, queued := p.inflight[job.ID]
+	p.inflight[job.ID] = struct{}{}
+	p.inflightMu.Unlock()
+
+	if queued {
+		return false, nil
+	}
+
+	select {
+	case p.queue <- job:
+		return true, nil
+	case <-ctx.Done():
+		p.done(job.ID)
+
+		return false, ctx.Err()
+	}
+}
+
+func (p *Pool) done(jobID int64) {
+	p.inflightMu.Lock()
+	delete(p.inflight, jobID)
+	p.inflightMu.Unlock()
+}
+
+// Run starts the workers and blocks until ctx is cancelled and the queue is
+// drained. Every RecoverAfter it re-queues the jobs that stopped making
+// progress, whether left by a previous run or lost by this one.
+func (p *Pool) Run(ctx context.Context) {
+	p.log.Info("job pool started", slog.Int("workers", p.cfg.Workers))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < p.cfg.Workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range p.queue {
+				p.process(ctx, job)
+			}
+		}()
+	}
+
+	p.recover(ctx)
+
+	ticker := time.NewTicker(p.cfg.RecoverAfter)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			p.recover(ctx)
+		}
+	}
+
+	p.mu.Lock()
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	wg.Wait()
+
+	p.log.Info("job pool stopped")
+}
+
+func (p *Pool) recover(ctx context.Context) {
+	const op = "worker.jobs.recover"
+
+	log := p.log.With(slog.String("op", op))
+
+	jobs, err := p.jobStorage.ListUnfinishedJobs(ctx, entity.JobKindEnrich, time.Now().Add(-p.cfg.RecoverAfter))
+	if err != nil {
+		log.Error("failed to list unfinished jobs", sl.Err(err))
+
+		return
+	}
+
+	requeued := 0
+	for _, job := range jobs {
+		queued, err := p.submit(ctx, job)
+		if err != nil {
+			break
+		}
+		if queued {
+			requeued++
+		}
+	}
+
+	if requeued > 0 {
+		log.Info("unfinished jobs re-queued", slog.Int("count", requeued))
+	}
+}
+
+func (p *Pool) process(ctx context.Context, job *entity.Job) {
+	defer p.done(job.ID)
+
+	log := p.log.With(slog.Int64("job_id", job.ID), slog.Int64("person_id", job.PersonID))
+
+	// Jobs still queued at shutdown are left for the next run to recover.
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Status updates must land even while shutting down.
+	bg := context.WithoutCancel(ctx)
+
+	if err := p.jobStorage.UpdateJobStatus(bg, job.ID, entity.JobRunning, ""); err != nil {
+		log.Error("failed to mark job as running", sl.Err(err))
+
+		return
+	}
+
+	status, errMsg := entity.JobSucceeded, ""
+	err := p.enrich(ctx, job.PersonID)
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		// Left running; recover picks it up once it is old enough.
+		return
+	case errors.Is(err, storage.ErrPersonClaimed):
+		log.Info("person is being enriched elsewhere")
+
+		status = entity.JobSkipped
+	case errors.Is(err, storage.ErrVersionConflict):
+		log.Info("person changed during enrichment, result discarded")
+
+		status = entity.JobSkipped
+	default:
+		log.Error("job failed", sl.Err(err))
+
+		status, errMsg = entity.JobFailed, err.Error()
+	}
+
+	if err := p.jobStorage.UpdateJobStatus(bg, job.ID, status, errMsg); err != nil {
+		log.Error("failed to update job status", sl.Err(err))
+
+		return
+	}
+
+	log.Info("job finished", slog.String("status", status))
+}
+
+// enrich leases the person first, so that the re-enrichment worker does not
+// look it up at the same time.
+func (p *Pool) enrich(ctx context.Context, personID int64) error {
+	person, err := p.jobStorage.ClaimPersonForEnrichment(ctx, personID, p.cfg.Lease)
+	if err != nil {
+		return err
+	}
+
+	personExtends, err := p.enricher.Enrich(ctx, person.Name)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	personExtends.Apply(person)
+
+	if err := p.jobStorage.ApplyEnrichment(context.WithoutCancel(ctx), person, person.Version); err != nil {
+		return err
+	}
+
+	if person.EnrichmentStatus == entity.EnrichmentFailed {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package reenrich
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/api"
+	"person-extender/internal/lib/logger/sl"
+	"person-extender/internal/storage"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Interval    time.Duration
+	StaleAfter  time.Duration
+	RetryAfter  time.Duration
+	Lease       time.Duration
+	BatchSize   int
+	Concurrency int
+}
+
+type PersonClaimer interface {
+	ClaimPersonsForEnrichment(ctx context.Context, limit int, retryBefore, staleBefore time.Time, lease time.Duration) ([]*entity.Person, error)
+	ApplyEnrichment(ctx context.Context, person *entity.Person, expectedVersion int64) error
+}
+
+type PersonEnricher interface {
+	Enrich(ctx context.Context, name string) (*api.PersonExtends, error)
+}
+
+// Worker periodically re-runs enrichment for persons that are pending,
+// partially or not at all enriched, or whose enrichment went stale.
+type Worker struct {
+	log           *slog.Logger
+	personClaimer PersonClaimer
+	enricher      PersonEnricher
+	cfg           Config
+}
+
+func New(log *slog.Logger, personClaimer PersonClaimer, enricher PersonEnricher, cfg Config) *Worker {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+
+	return &Worker{
+		log:           log.With(slog.String("component", "worker/reenrich")),
+		personClaimer: personClaimer,
+		enricher:      enricher,
+		cfg:           cfg,
+	}
+}
+
+// Run polls until ctx is cancelled and returns once in-flight persons are
+// processed.
+func (w *Worker) Run(ctx context.Context) {
+	w.log.Info("re-enrichment worker started")
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back, then wait for the next tick.
+		for {
+			if n := w.runOnce(ctx); n < w.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			w.log.Info("re-enrichment worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) runOnce(ctx context.Context) int {
+	const op = "worker.reenrich.runOnce"
+
+	log := w.log.With(slog.String("op", op))
+
+	now := time.Now()
+
+	persons, err := w.personClaimer.ClaimPersonsForEnrichment(ctx, w.cfg.BatchSize, now.Add(-w.cfg.RetryAfter), now.Add(-w.cfg.StaleAfter), w.cfg.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error("failed to claim persons", sl.Err(err))
+		}
+
+		return 0
+	}
+
+	if len(persons) == 0 {
+		return 0
+	}
+
+	log.Debug("persons claimed", slog.Int("count", len(persons)))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, w.cfg.Concurrency)
+
+	for _, person := range persons {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(person *entity.Person) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			w.process(ctx, person)
+		}(person)
+	}
+
+	wg.Wait()
+
+	return len(persons)
+}
+
+func (w *Worker) process(ctx context.Context, person *entity.Person) {
+	log := w.log.With(slog.Int64("id", person.ID))
+
+	personExtends, err := w.enricher.Enrich(ctx, person.Name)
+	if ctx.Err() != nil {
+		// Leave the row to expire its lease so another run picks it up.
+		return
+	}
+	if err != nil {
+		log.Warn("person extends are incomplete", sl.Err(err))
+	}
+
+	personExtends.Apply(person)
+
+	// The lookups finished, so their result is stored even if shutdown
+	// begins meanwhile; that also releases the claim.
+	err = w.personClaimer.ApplyEnrichment(context.WithoutCancel(ctx), person, person.Version)
+	if errors.Is(err, storage.ErrPersonNotFound) {
+		log.Info("person was deleted during re-enrichment")
+
+		return
+	}
+	if errors.Is(err, storage.ErrVersionConflict) {
+		log.Info("person changed during re-enrichment, result discarded")
+
+		return
+	}
+	if err != nil {
+		log.Error("failed to apply enrichment", sl.Err(err))
+
+		return
+	}
+
+	log.Info("person re-enriched", slog.String("enrichment_status", person.EnrichmentStatus))
+}
@@ -0,0 +1,93 @@
+package reenrich
+
+import (
+	"context"
+	"log/slog"
+	"person-extender/internal/entity"
+	"person-extender/internal/lib/logger/sl"
+	"time"
+)
+
+type RefreshStorage interface {
+	GetPerson(ctx context.Context, ID int64) (*entity.Person, error)
+	ClaimPersonForEnrichment(ctx context.Context, ID int64, lease time.Duration) (*entity.Person, error)
+	ApplyEnrichment(ctx context.Context, person *entity.Person, expectedVersion int64) error
+}
+
+type JobEnqueuer interface {
+	Enqueue(ctx context.Context, personID int64) (*entity.Job, error)
+}
+
+// Refresher re-runs enrichment for a single person on demand: inline, or
+// through the job pool when jobEnqueuer is set.
+type Refresher struct {
+	log            *slog.Logger
+	refreshStorage RefreshStorage
+	enricher       PersonEnricher
+	jobEnqueuer    JobEnqueuer
+	lease          time.Duration
+}
+
+// NewRefresher returns a Refresher; lease bounds how long an inline refresh
+// keeps the person claimed.
+func NewRefresher(log *slog.Logger, refreshStorage RefreshStorage, enricher PersonEnricher, jobEnqueuer JobEnqueuer, lease time.Duration) *Refresher {
+	return &Refresher{
+		log:            log.With(slog.String("component", "worker/reenrich")),
+		refreshStorage: refreshStorage,
+		enricher:       enricher,
+		jobEnqueuer:    jobEnqueuer,
+		lease:          lease,
+	}
+}
+
+// Refresh returns the re-enriched person when run inline, or the queued job
+// otherwise. It fails with storage.ErrPersonNotFound for unknown persons and,
+// inline, with storage.ErrPersonClaimed while someone else enriches the
+// person or storage.ErrVersionConflict if it changed during the lookups.
+func (r *Refresher) Refresh(ctx context.Context, ID int64) (*entity.Person, *entity.Job, error) {
+	const op = "worker.reenrich.Refresh"
+
+	log := r.log.With(slog.String("op", op), slog.Int64("id", ID))
+
+	if r.jobEnqueuer != nil {
+		person, err := r.refreshStorage.GetPerson(ctx, ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		job, err := r.jobEnqueuer.Enqueue(ctx, ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return person, job, nil
+	}
+
+	// The claim keeps the workers off the person during the lookups.
+	person, err := r.refreshStorage.ClaimPersonForEnrichment(ctx, ID, r.lease)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	personExtends, err := r.enricher.Enrich(ctx, person.Name)
+	if ctx.Err() != nil {
+		// The lease runs out and the workers pick the person up again.
+		return nil, nil, ctx.Err()
+	}
+	if err != nil {
+		log.Warn("person extends are incomplete", sl.Err(err))
+	}
+
+	personExtends.Apply(person)
+
+	if err := r.refreshStorage.ApplyEnrichment(context.WithoutCancel(ctx), person, person.Version); err != nil {
+		return nil, nil, err
+	}
+
+	person, err = r.refreshStorage.GetPerson(ctx, ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return person, nil, nil
+}